@@ -0,0 +1,12 @@
+package collections
+
+// Codec converts values of type T to and from bytes, for the binary encodings
+// of the collections holding them.
+type Codec[T any] interface {
+	// Append appends the encoding of v to buf and returns the extended
+	// buffer.
+	Append(buf []byte, v T) ([]byte, error)
+	// Decode decodes a value from the whole of data, which it must copy if
+	// it retains any part of it.
+	Decode(data []byte) (T, error)
+}
@@ -21,6 +21,25 @@ func LastSelection(n uint) *Selection {
 	return &Selection{n:n,x:z}
 }
 
+// SelectionOf returns the selection from n elements whose members are the
+// set bits of x.  x is copied.
+func SelectionOf(n uint, x *big.Int) *Selection {
+	if x.Sign() < 0 || x.BitLen() > int(n) {
+		panic("invalid selection")
+	}
+	return &Selection{n:n,x:new(big.Int).Set(x)}
+}
+
+// Len returns the number of elements the selection is made from.
+func (s *Selection) Len() uint {
+	return s.n
+}
+
+// Bits returns a copy of the bitmask of the selection.
+func (s *Selection) Bits() *big.Int {
+	return new(big.Int).Set(s.x)
+}
+
 func (s *Selection) HasNext() bool {
 	z := big.NewInt(1)
 	return z.Add(s.x,z).BitLen() <= int(s.n)
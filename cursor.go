@@ -1,10 +1,79 @@
 package collections
 
-// Cursor is an iterator type that provides access to an ordered list of
-// Comparable receivers.
-type Cursor interface {
+import "iter"
+
+// CursorOf is an iterator type that provides access to an ordered list of
+// values of type T.
+type CursorOf[T any] interface {
 	HasNext() bool
 	HasPrev() bool
-	Next() Comparable
-	Prev() Comparable
+	Next() T
+	Prev() T
+}
+
+// Cursor is an iterator type that provides access to an ordered list of
+// Comparable receivers.
+type Cursor = CursorOf[Comparable]
+
+// MutableCursorOf is a cursor which can also modify its collection at the
+// value it most recently returned.  Remove deletes that value, leaving the
+// cursor in the gap where it was, and Set replaces it in place with a value
+// that must compare equal to it.  Both panic if the preceding move returned
+// no value, and Remove may be called only once per move.
+type MutableCursorOf[T any] interface {
+	CursorOf[T]
+	Remove()
+	Set(v T)
+}
+
+// MutableCursor is a MutableCursorOf Comparable receivers.
+type MutableCursor = MutableCursorOf[Comparable]
+
+// Values returns a sequence of the values remaining in a cursor, advancing it
+// with Next() as the sequence is consumed.
+func Values[T any](cur CursorOf[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for cur.HasNext() {
+			if !yield(cur.Next()) {
+				return
+			}
+		}
+	}
+}
+
+// FromSeq returns a cursor positioned before the first value of a sequence.
+// The sequence is read to the end up front, so it must be finite.
+func FromSeq[T any](seq iter.Seq[T]) CursorOf[T] {
+	sc := &seqCursor[T]{}
+	for v := range seq {
+		sc.vals = append(sc.vals, v)
+	}
+	return sc
+}
+
+type seqCursor[T any] struct {
+	vals []T
+	pos  int
+}
+
+func (sc *seqCursor[T]) HasNext() bool { return sc.pos < len(sc.vals) }
+
+func (sc *seqCursor[T]) HasPrev() bool { return sc.pos > 0 }
+
+func (sc *seqCursor[T]) Next() T {
+	var zero T
+	if sc.pos >= len(sc.vals) {
+		return zero
+	}
+	sc.pos++
+	return sc.vals[sc.pos-1]
+}
+
+func (sc *seqCursor[T]) Prev() T {
+	var zero T
+	if sc.pos <= 0 {
+		return zero
+	}
+	sc.pos--
+	return sc.vals[sc.pos]
 }
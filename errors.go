@@ -0,0 +1,9 @@
+package collections
+
+// ConcurrentModificationError is the panic value of a fail-fast cursor used
+// after the collection it iterates has been modified by other means.
+type ConcurrentModificationError struct{}
+
+func (e *ConcurrentModificationError) Error() string {
+	return "collection modified during iteration"
+}
@@ -0,0 +1,9 @@
+package collections
+
+// Hashable is received by types usable in a hash-based collection.  Equal
+// reports whether the receiver and argument are the same value, and values
+// which are Equal must return the same Hash.
+type Hashable interface {
+	Hash() uint64
+	Equal(o Hashable) bool
+}
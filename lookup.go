@@ -8,4 +8,12 @@ const (
 	GTE LookupType = iota
 	// LTE searches for the largest value <= search argument.
 	LTE
+	// LT searches for the largest value < search argument.
+	LT
+	// GT searches for the smallest value > search argument.
+	GT
+	// EQ searches for a value equal to the search argument.  A cursor opened
+	// with EQ on a missing argument is positioned where the argument would
+	// be, as for GTE.
+	EQ
 )
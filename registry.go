@@ -0,0 +1,177 @@
+package collections
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"reflect"
+	"sync"
+)
+
+// ComparableDecoder builds a Comparable value from its encoding.  It calls
+// unmarshal with a pointer to a variable of its choosing, which unmarshal
+// fills from the encoded value (as json.Unmarshal or a gob.Decoder does), and
+// returns the value built from it.
+type ComparableDecoder func(unmarshal func(v any) error) (Comparable, error)
+
+// The registry maps the names under which Comparable types are encoded in the
+// JSON and gob encodings of collections to their decoders, and their types
+// to their names.
+var registry struct {
+	sync.RWMutex
+	decoders map[string]ComparableDecoder
+	names    map[reflect.Type]string
+}
+
+// RegisterDecoder registers the type of sample under name for the JSON and
+// gob encodings of collections.  Values of the type are encoded as themselves
+// and decoded by decode.  It panics if the name or the type is already
+// registered.
+func RegisterDecoder(name string, sample Comparable, decode ComparableDecoder) {
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.decoders == nil {
+		registry.decoders = make(map[string]ComparableDecoder)
+		registry.names = make(map[reflect.Type]string)
+	}
+	rt := reflect.TypeOf(sample)
+	if _, dup := registry.decoders[name]; dup {
+		panic(fmt.Sprintf("collections: name %q registered twice", name))
+	}
+	if _, dup := registry.names[rt]; dup {
+		panic(fmt.Sprintf("collections: type %v registered twice", rt))
+	}
+	registry.decoders[name] = decode
+	registry.names[rt] = name
+}
+
+// Register registers the Comparable type T under name, as for RegisterDecoder,
+// with values decoded directly into a T.
+func Register[T Comparable](name string) {
+	var sample T
+	RegisterDecoder(name, sample, func(unmarshal func(v any) error) (Comparable, error) {
+		var v T
+		err := unmarshal(&v)
+		return v, err
+	})
+}
+
+// registeredName returns the name under which the type of x is registered.
+func registeredName(x Comparable) (string, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	name, ok := registry.names[reflect.TypeOf(x)]
+	if !ok {
+		return "", fmt.Errorf("collections: type %T is not registered", x)
+	}
+	return name, nil
+}
+
+// registeredDecoder returns the decoder registered under name.
+func registeredDecoder(name string) (ComparableDecoder, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	decode, ok := registry.decoders[name]
+	if !ok {
+		return nil, fmt.Errorf("collections: no decoder registered for %q", name)
+	}
+	return decode, nil
+}
+
+// jsonElement is the JSON encoding of a value in a collection.
+type jsonElement struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MarshalJSONElements encodes a sequence of values of registered types as a
+// JSON array, in which each value is an object holding the name of its type
+// and its own JSON encoding: {"type": name, "value": value}.
+func MarshalJSONElements(vals iter.Seq[Comparable]) ([]byte, error) {
+	elems := []jsonElement{}
+	for v := range vals {
+		name, err := registeredName(v)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, jsonElement{name, raw})
+	}
+	return json.Marshal(elems)
+}
+
+// UnmarshalJSONElements decodes the values encoded by MarshalJSONElements, in
+// order, through the decoders registered for their types.
+func UnmarshalJSONElements(data []byte) ([]Comparable, error) {
+	var elems []jsonElement
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return nil, err
+	}
+	vals := make([]Comparable, 0, len(elems))
+	for _, e := range elems {
+		decode, err := registeredDecoder(e.Type)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decode(func(v any) error { return json.Unmarshal(e.Value, v) })
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// GobEncodeElements encodes a sequence of values of registered types as a gob
+// stream, in which each value is preceded by the name of its type and the
+// last is followed by an empty name.
+func GobEncodeElements(vals iter.Seq[Comparable]) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := gob.NewEncoder(&buf)
+	for v := range vals {
+		name, err := registeredName(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(name); err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Encode(""); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecodeElements decodes the values encoded by GobEncodeElements, in order,
+// through the decoders registered for their types.
+func GobDecodeElements(data []byte) ([]Comparable, error) {
+	dec := gob.NewDecoder(bytes.NewReader(data))
+	var vals []Comparable
+	for {
+		var name string
+		if err := dec.Decode(&name); err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return vals, nil
+		}
+		decode, err := registeredDecoder(name)
+		if err != nil {
+			return nil, err
+		}
+		v, err := decode(dec.Decode)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+}
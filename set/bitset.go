@@ -0,0 +1,372 @@
+package set
+
+import (
+	"iter"
+	"math/big"
+	"math/bits"
+
+	c "github.com/dtromb/collections"
+	"github.com/dtromb/collections/combinatorics"
+)
+
+// Int is a Comparable and Hashable integer, the element type of BitSet.
+type Int int
+
+func (i Int) CompareTo(o c.Comparable) int8 {
+	oi := o.(Int)
+	if i < oi {
+		return -1
+	}
+	if i > oi {
+		return 1
+	}
+	return 0
+}
+
+func (i Int) Hash() uint64 { return uint64(i) }
+
+func (i Int) Equal(o c.Hashable) bool {
+	oi, ok := o.(Int)
+	return ok && i == oi
+}
+
+// BitSet is a dense ordered MutableSet of non-negative Int elements, holding
+// one bit per possible element up to the largest present.  Operations
+// between two BitSets work a word (64 elements) at a time.  The zero value is
+// an empty set.
+type BitSet struct {
+	words []uint64
+}
+
+// bitCursor sits in the gap before element pos.
+type bitCursor struct {
+	bs  *BitSet
+	pos int
+}
+
+// NewBitSet returns a BitSet holding the arguments, which must be
+// non-negative.
+func NewBitSet(xs ...int) *BitSet {
+	bs := &BitSet{}
+	for _, x := range xs {
+		bs.set(x)
+	}
+	return bs
+}
+
+// BitSetFromSelection returns a BitSet holding the members of a selection.
+func BitSetFromSelection(s *combinatorics.Selection) *BitSet {
+	x := s.Bits()
+	bs := &BitSet{}
+	for i := x.BitLen() - 1; i >= 0; i-- {
+		if x.Bit(i) != 0 {
+			bs.set(i)
+		}
+	}
+	return bs
+}
+
+// ToSelection returns the selection from n elements with the members of the
+// set.  It panics if the set holds an element of n or more.
+func (bs *BitSet) ToSelection(n uint) *combinatorics.Selection {
+	x := new(big.Int)
+	for i := bs.NextSetBit(0); i >= 0; i = bs.NextSetBit(i + 1) {
+		x.SetBit(x, i, 1)
+	}
+	return combinatorics.SelectionOf(n, x)
+}
+
+// set adds i to the set, returning whether it was absent.
+func (bs *BitSet) set(i int) bool {
+	if i < 0 {
+		panic("BitSet elements must be non-negative")
+	}
+	w := i / 64
+	for len(bs.words) <= w {
+		bs.words = append(bs.words, 0)
+	}
+	m := uint64(1) << (i % 64)
+	if bs.words[w]&m != 0 {
+		return false
+	}
+	bs.words[w] |= m
+	return true
+}
+
+// clear removes i from the set, returning whether it was present.
+func (bs *BitSet) clear(i int) bool {
+	if !bs.Test(i) {
+		return false
+	}
+	bs.words[i/64] &^= uint64(1) << (i % 64)
+	bs.trim()
+	return true
+}
+
+// trim drops the zero words at the end of the set.
+func (bs *BitSet) trim() {
+	n := len(bs.words)
+	for n > 0 && bs.words[n-1] == 0 {
+		n--
+	}
+	bs.words = bs.words[:n]
+}
+
+// Test checks whether i is in the set.
+func (bs *BitSet) Test(i int) bool {
+	w := i / 64
+	return i >= 0 && w < len(bs.words) && bs.words[w]&(uint64(1)<<(i%64)) != 0
+}
+
+// NextSetBit returns the smallest element of the set >= i, or -1 if there
+// is none.
+func (bs *BitSet) NextSetBit(i int) int {
+	if i < 0 {
+		i = 0
+	}
+	w := i / 64
+	if w >= len(bs.words) {
+		return -1
+	}
+	word := bs.words[w] >> (i % 64) << (i % 64)
+	for {
+		if word != 0 {
+			return w*64 + bits.TrailingZeros64(word)
+		}
+		w++
+		if w == len(bs.words) {
+			return -1
+		}
+		word = bs.words[w]
+	}
+}
+
+// PrevSetBit returns the largest element of the set <= i, or -1 if there is
+// none.
+func (bs *BitSet) PrevSetBit(i int) int {
+	if i < 0 || len(bs.words) == 0 {
+		return -1
+	}
+	w := i / 64
+	var word uint64
+	if w >= len(bs.words) {
+		w = len(bs.words) - 1
+		word = bs.words[w]
+	} else {
+		word = bs.words[w] << (63 - i%64) >> (63 - i%64)
+	}
+	for {
+		if word != 0 {
+			return w*64 + 63 - bits.LeadingZeros64(word)
+		}
+		w--
+		if w < 0 {
+			return -1
+		}
+		word = bs.words[w]
+	}
+}
+
+func (bs *BitSet) Ordered() bool { return true }
+
+func (bs *BitSet) CompareTo(c c.Comparable) int8 {
+	return compareSets(bs, c)
+}
+
+// Size returns the number of elements in the set, by population count.
+func (bs *BitSet) Size() int {
+	var n int
+	for _, w := range bs.words {
+		n += bits.OnesCount64(w)
+	}
+	return n
+}
+
+func (bs *BitSet) Contains(cm c.Comparable) bool {
+	i, ok := cm.(Int)
+	return ok && bs.Test(int(i))
+}
+
+func (bs *BitSet) Add(cs ...c.Comparable) int {
+	var n int
+	for _, k := range cs {
+		if bs.set(int(k.(Int))) {
+			n++
+		}
+	}
+	return n
+}
+
+func (bs *BitSet) Remove(cs ...c.Comparable) int {
+	var n int
+	for _, k := range cs {
+		if i, ok := k.(Int); ok && bs.clear(int(i)) {
+			n++
+		}
+	}
+	return n
+}
+
+func (bs *BitSet) Retain(cs ...c.Comparable) int {
+	keep := make(map[int]bool, len(cs))
+	for _, k := range cs {
+		if i, ok := k.(Int); ok {
+			keep[int(i)] = true
+		}
+	}
+	return bs.filter(func(i int) bool { return keep[i] })
+}
+
+// bitSetOf returns s as a BitSet, converting it if it is some other kind of
+// set.  It panics if s holds an element which cannot be held in a BitSet.
+func bitSetOf(s Set) *BitSet {
+	if bs, ok := s.(*BitSet); ok {
+		return bs
+	}
+	bs := &BitSet{}
+	for k := range s.All() {
+		if i, ok := k.(Int); ok && i >= 0 {
+			bs.set(int(i))
+		} else {
+			panic("BitSet elements must be non-negative Ints")
+		}
+	}
+	return bs
+}
+
+// combine sets each word of the receiver to op of it and the matching word
+// of o, returning the number of elements changed.  The receiver grows to the
+// length of o only if grow is set, so op must otherwise map a zero word of
+// the receiver to zero.
+func (bs *BitSet) combine(o *BitSet, grow bool, op func(a, b uint64) uint64) int {
+	for grow && len(bs.words) < len(o.words) {
+		bs.words = append(bs.words, 0)
+	}
+	var n int
+	for i, w := range bs.words {
+		var ow uint64
+		if i < len(o.words) {
+			ow = o.words[i]
+		}
+		nw := op(w, ow)
+		n += bits.OnesCount64(w ^ nw)
+		bs.words[i] = nw
+	}
+	bs.trim()
+	return n
+}
+
+// filter removes the elements for which keep returns false, returning the
+// number removed.  It takes time in the size of the receiver, whatever keep
+// consults.
+func (bs *BitSet) filter(keep func(i int) bool) int {
+	var n int
+	for w, word := range bs.words {
+		for ; word != 0; word &= word - 1 {
+			b := bits.TrailingZeros64(word)
+			if !keep(w*64 + b) {
+				bs.words[w] &^= uint64(1) << b
+				n++
+			}
+		}
+	}
+	bs.trim()
+	return n
+}
+
+func (bs *BitSet) AddAll(s Set) int {
+	return bs.combine(bitSetOf(s), true, func(a, b uint64) uint64 { return a | b })
+}
+
+// RemoveAll removes the elements of s.  Unless s is a BitSet, each element of
+// the receiver is looked up in s, so a sparse s with large elements costs no
+// more than a small one.
+func (bs *BitSet) RemoveAll(s Set) int {
+	if o, ok := s.(*BitSet); ok {
+		return bs.combine(o, false, func(a, b uint64) uint64 { return a &^ b })
+	}
+	return bs.filter(func(i int) bool { return !s.Contains(Int(i)) })
+}
+
+// RetainAll removes the elements not in s, looking them up in s as for
+// RemoveAll.
+func (bs *BitSet) RetainAll(s Set) int {
+	if o, ok := s.(*BitSet); ok {
+		return bs.combine(o, false, func(a, b uint64) uint64 { return a & b })
+	}
+	return bs.filter(func(i int) bool { return s.Contains(Int(i)) })
+}
+
+func (bs *BitSet) Clear() {
+	bs.words = nil
+}
+
+// Clone returns a copy of the set.
+func (bs *BitSet) Clone() *BitSet {
+	return &BitSet{words: append([]uint64(nil), bs.words...)}
+}
+
+func (bs *BitSet) Union(s Set) Set {
+	ns := bs.Clone()
+	ns.AddAll(s)
+	return ns
+}
+
+func (bs *BitSet) Intersection(s Set) Set {
+	ns := bs.Clone()
+	ns.RetainAll(s)
+	return ns
+}
+
+func (bs *BitSet) Difference(s Set) Set {
+	ns := bs.Clone()
+	ns.RemoveAll(s)
+	return ns
+}
+
+func (bs *BitSet) SymmetricDifference(s Set) Set {
+	ns := bs.Clone()
+	ns.combine(bitSetOf(s), true, func(a, b uint64) uint64 { return a ^ b })
+	return ns
+}
+
+func (bs *BitSet) All() iter.Seq[c.Comparable] {
+	return func(yield func(c.Comparable) bool) {
+		for w, word := range bs.words {
+			for word != 0 {
+				if !yield(Int(w*64 + bits.TrailingZeros64(word))) {
+					return
+				}
+				word &= word - 1
+			}
+		}
+	}
+}
+
+// OpenCursor opens a cursor positioned before the smallest element.  It
+// follows later changes to the set.
+func (bs *BitSet) OpenCursor() c.Cursor {
+	return &bitCursor{bs: bs}
+}
+
+func (bc *bitCursor) HasNext() bool { return bc.bs.NextSetBit(bc.pos) >= 0 }
+
+func (bc *bitCursor) HasPrev() bool { return bc.bs.PrevSetBit(bc.pos-1) >= 0 }
+
+func (bc *bitCursor) Next() c.Comparable {
+	i := bc.bs.NextSetBit(bc.pos)
+	if i < 0 {
+		return nil
+	}
+	bc.pos = i + 1
+	return Int(i)
+}
+
+func (bc *bitCursor) Prev() c.Comparable {
+	i := bc.bs.PrevSetBit(bc.pos - 1)
+	if i < 0 {
+		return nil
+	}
+	bc.pos = i
+	return Int(i)
+}
@@ -0,0 +1,214 @@
+package set
+
+import (
+	"encoding/gob"
+	"fmt"
+	"slices"
+
+	c "github.com/dtromb/collections"
+	"github.com/dtromb/collections/tree"
+)
+
+func init() {
+	c.Register[Int]("set.Int")
+	// Sets are encoded through their own methods; registering the shapes
+	// lets gob carry them in fields and values of interface type Set.
+	gob.Register(&emptySet{})
+	gob.Register(&singletonSet{})
+	gob.Register(&pairSet{})
+	gob.Register(&treeSet{})
+	gob.Register(&rangedTreeSet{})
+}
+
+// TreeSetWithCodec returns a TreeSet whose binary encoding uses codec for its
+// elements.  A TreeSet without a codec of its own uses that of its tree, if
+// the tree is an AvlTree.
+func TreeSetWithCodec(tree tree.Tree, codec c.Codec[c.Comparable]) MutableSet {
+	return newTreeSet(treeSet{tree: tree, codec: codec})
+}
+
+// elementCodec returns the codec for the elements of the set, or nil.
+func (ts *treeSet) elementCodec() c.Codec[c.Comparable] {
+	if ts.codec != nil {
+		return ts.codec
+	}
+	if at, ok := ts.tree.(*tree.AvlTree); ok {
+		return at.Codec()
+	}
+	return nil
+}
+
+// MarshalBinary encodes the set as a sorted stream, as for tree.EncodeSorted,
+// in O(n) time.  It implements encoding.BinaryMarshaler.
+func (ts *treeSet) MarshalBinary() ([]byte, error) {
+	return tree.EncodeSorted(ts.tree.Size(), ts.All(), ts.elementCodec())
+}
+
+// UnmarshalBinary replaces the contents of the set with the values of a
+// sorted stream.  The set keeps its tree: an AvlTree is rebuilt in place in
+// O(n) time, and other trees and views are refilled value by value.  The set
+// is left unchanged if the stream cannot be decoded, or holds a value outside
+// the range of a view.  It implements encoding.BinaryUnmarshaler.
+func (ts *treeSet) UnmarshalBinary(data []byte) error {
+	vals, err := tree.DecodeSorted(data, ts.elementCodec(), func(a, b c.Comparable) int { return int(a.CompareTo(b)) })
+	if err != nil {
+		return err
+	}
+	return ts.setElements(vals)
+}
+
+// sortedElements sorts decoded elements and removes duplicates, keeping the
+// last of equal values as Add would.
+func sortedElements(vals []c.Comparable) []c.Comparable {
+	slices.SortStableFunc(vals, func(a, b c.Comparable) int { return int(a.CompareTo(b)) })
+	var out []c.Comparable
+	for _, v := range vals {
+		if k := len(out); k > 0 && out[k-1].CompareTo(v) == 0 {
+			out[k-1] = v
+		} else {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// ofElements returns the smallest kind of set holding the elements of a
+// sorted slice without duplicates.
+func ofElements(vals []c.Comparable) Set {
+	switch len(vals) {
+	case 0:
+		return Empty()
+	case 1:
+		return Singleton(vals[0])
+	case 2:
+		return &pairSet{x: vals[0], y: vals[1]}
+	}
+	return TreeSet(tree.BuildFromSortedSlice(vals))
+}
+
+// DecodeJSON decodes a set encoded by the MarshalJSON method of any set, as
+// the smallest kind of set holding its elements: an empty, singleton or pair
+// set, or else a TreeSet.
+func DecodeJSON(data []byte) (Set, error) {
+	vals, err := c.UnmarshalJSONElements(data)
+	if err != nil {
+		return nil, err
+	}
+	return ofElements(sortedElements(vals)), nil
+}
+
+// shapeError returns the error for decoding n elements into a set of a fixed
+// size.
+func shapeError(kind string, n int) error {
+	return fmt.Errorf("set: cannot decode %d distinct elements into a %s set", n, kind)
+}
+
+// The set kinds implement json.Marshaler and json.Unmarshaler, and
+// gob.GobEncoder and gob.GobDecoder, through the element encodings of the
+// collections package, whose registry resolves the types of the elements.
+// Each kind decodes elements in any order; the empty, singleton and pair sets
+// fail to decode any other number of distinct elements.
+
+func (es *emptySet) MarshalJSON() ([]byte, error) { return c.MarshalJSONElements(es.All()) }
+
+func (es *emptySet) GobEncode() ([]byte, error) { return c.GobEncodeElements(es.All()) }
+
+func (es *emptySet) UnmarshalJSON(data []byte) error {
+	return decodeElements(data, c.UnmarshalJSONElements, es.setElements)
+}
+
+func (es *emptySet) GobDecode(data []byte) error {
+	return decodeElements(data, c.GobDecodeElements, es.setElements)
+}
+
+func (es *emptySet) setElements(vals []c.Comparable) error {
+	if len(vals) != 0 {
+		return shapeError("empty", len(vals))
+	}
+	return nil
+}
+
+func (ss *singletonSet) MarshalJSON() ([]byte, error) { return c.MarshalJSONElements(ss.All()) }
+
+func (ss *singletonSet) GobEncode() ([]byte, error) { return c.GobEncodeElements(ss.All()) }
+
+func (ss *singletonSet) UnmarshalJSON(data []byte) error {
+	return decodeElements(data, c.UnmarshalJSONElements, ss.setElements)
+}
+
+func (ss *singletonSet) GobDecode(data []byte) error {
+	return decodeElements(data, c.GobDecodeElements, ss.setElements)
+}
+
+func (ss *singletonSet) setElements(vals []c.Comparable) error {
+	if len(vals) != 1 {
+		return shapeError("singleton", len(vals))
+	}
+	ss.x = vals[0]
+	return nil
+}
+
+func (ps *pairSet) MarshalJSON() ([]byte, error) { return c.MarshalJSONElements(ps.All()) }
+
+func (ps *pairSet) GobEncode() ([]byte, error) { return c.GobEncodeElements(ps.All()) }
+
+func (ps *pairSet) UnmarshalJSON(data []byte) error {
+	return decodeElements(data, c.UnmarshalJSONElements, ps.setElements)
+}
+
+func (ps *pairSet) GobDecode(data []byte) error {
+	return decodeElements(data, c.GobDecodeElements, ps.setElements)
+}
+
+func (ps *pairSet) setElements(vals []c.Comparable) error {
+	if len(vals) != 2 {
+		return shapeError("pair", len(vals))
+	}
+	ps.x, ps.y = vals[0], vals[1]
+	return nil
+}
+
+func (ts *treeSet) MarshalJSON() ([]byte, error) { return c.MarshalJSONElements(ts.All()) }
+
+func (ts *treeSet) GobEncode() ([]byte, error) { return c.GobEncodeElements(ts.All()) }
+
+func (ts *treeSet) UnmarshalJSON(data []byte) error {
+	return decodeElements(data, c.UnmarshalJSONElements, ts.setElements)
+}
+
+func (ts *treeSet) GobDecode(data []byte) error {
+	return decodeElements(data, c.GobDecodeElements, ts.setElements)
+}
+
+// setElements replaces the contents of the set with sorted elements, as
+// UnmarshalBinary does.
+func (ts *treeSet) setElements(vals []c.Comparable) error {
+	if ts.tree == nil {
+		ts.tree = tree.BuildFromSortedSlice(vals)
+		return nil
+	}
+	if at, ok := ts.tree.(*tree.AvlTree); ok {
+		at.Rebuild(vals)
+		return nil
+	}
+	if rt, ok := ts.tree.(tree.RangedTree); ok {
+		for _, v := range vals {
+			if !rt.InRange(v) {
+				return fmt.Errorf("set: cannot decode %v outside the range of a view", v)
+			}
+		}
+	}
+	ts.Clear()
+	ts.Add(vals...)
+	return nil
+}
+
+// decodeElements decodes elements with decode and passes them, sorted and
+// without duplicates, to set.
+func decodeElements(data []byte, decode func([]byte) ([]c.Comparable, error), set func([]c.Comparable) error) error {
+	vals, err := decode(data)
+	if err != nil {
+		return err
+	}
+	return set(sortedElements(vals))
+}
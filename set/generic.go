@@ -0,0 +1,202 @@
+package set
+
+import (
+	"cmp"
+	"iter"
+	"slices"
+
+	c "github.com/dtromb/collections"
+	tree "github.com/dtromb/collections/tree"
+)
+
+// SetOf is a set of values of type T, with the methods of Set.  Unlike Set
+// its elements need not implement Comparable; an ordered SetOf orders them by
+// the comparison function it was created with.  CompareTo orders sets as for
+// Set, by size and then by their elements in ascending order.
+type SetOf[T any] interface {
+	CompareTo(s SetOf[T]) int8
+	Size() int
+	Contains(x T) bool
+	Union(s SetOf[T]) SetOf[T]
+	Intersection(s SetOf[T]) SetOf[T]
+	Difference(s SetOf[T]) SetOf[T]
+	SymmetricDifference(s SetOf[T]) SetOf[T]
+	OpenCursor() c.CursorOf[T]
+	All() iter.Seq[T]
+	Ordered() bool
+}
+
+// MutableSetOf is a SetOf which can be modified in place, with the methods
+// of MutableSet.  Each modifying operation returns the number of elements
+// added or removed.
+type MutableSetOf[T any] interface {
+	SetOf[T]
+	Add(xs ...T) int
+	Remove(xs ...T) int
+	Retain(xs ...T) int
+	AddAll(s SetOf[T]) int
+	RemoveAll(s SetOf[T]) int
+	RetainAll(s SetOf[T]) int
+	Clear()
+}
+
+type orderedSet[T any] struct {
+	tree *tree.AvlTreeOf[T]
+}
+
+// NewSetOf creates an empty set ordered by cmp, which must return a negative
+// value, zero or a positive value as a orders before, equal to or after b.
+func NewSetOf[T any](cmp func(a, b T) int, xs ...T) MutableSetOf[T] {
+	os := &orderedSet[T]{tree: tree.NewAvlTreeOf(cmp)}
+	os.Add(xs...)
+	return os
+}
+
+// NewOrderedSet creates a set of values in their natural order.
+func NewOrderedSet[T cmp.Ordered](xs ...T) MutableSetOf[T] {
+	return NewSetOf(cmp.Compare[T], xs...)
+}
+
+func (os *orderedSet[T]) Ordered() bool { return true }
+
+func (os *orderedSet[T]) CompareTo(s SetOf[T]) int8 {
+	oz, tz := s.Size(), os.Size()
+	if tz < oz {
+		return -1
+	}
+	if tz > oz {
+		return 1
+	}
+	compare := os.tree.Comparator()
+	ovs := slices.Collect(s.All())
+	if !s.Ordered() {
+		slices.SortFunc(ovs, compare)
+	}
+	i := 0
+	for k := range os.All() {
+		if r := compare(k, ovs[i]); r != 0 {
+			return int8(max(-1, min(1, r)))
+		}
+		i++
+	}
+	return 0
+}
+
+func (os *orderedSet[T]) Size() int { return int(os.tree.Size()) }
+
+func (os *orderedSet[T]) Contains(x T) bool {
+	return os.tree.Has(x)
+}
+
+// combine merges the receiver with s in O(n+m) time, keeping the elements
+// selected by keep as for mergeOrdered, if s is an orderedSet as well;
+// otherwise it returns nil.
+func (os *orderedSet[T]) combine(s SetOf[T], keep int) SetOf[T] {
+	so, ok := s.(*orderedSet[T])
+	if !ok {
+		return nil
+	}
+	compare := os.tree.Comparator()
+	nt := tree.NewAvlTreeOf(compare)
+	nt.SetCodec(os.tree.Codec())
+	nt.Rebuild(mergeOrderedOf(os.OpenCursor(), so.OpenCursor(), compare, keep))
+	return &orderedSet[T]{tree: nt}
+}
+
+func (os *orderedSet[T]) Union(s SetOf[T]) SetOf[T] {
+	if r := os.combine(s, keepLeft|keepRight|keepBoth); r != nil {
+		return r
+	}
+	r := &orderedSet[T]{tree: os.tree.Clone()}
+	sc := s.OpenCursor()
+	for sc.HasNext() {
+		r.tree.Insert(sc.Next())
+	}
+	return r
+}
+
+func (os *orderedSet[T]) Intersection(s SetOf[T]) SetOf[T] {
+	if r := os.combine(s, keepBoth); r != nil {
+		return r
+	}
+	r := &orderedSet[T]{tree: tree.NewAvlTreeOf(os.tree.Comparator())}
+	cc := os.OpenCursor()
+	for cc.HasNext() {
+		k := cc.Next()
+		if s.Contains(k) {
+			r.tree.Insert(k)
+		}
+	}
+	return r
+}
+
+func (os *orderedSet[T]) Difference(s SetOf[T]) SetOf[T] {
+	if r := os.combine(s, keepLeft); r != nil {
+		return r
+	}
+	r := &orderedSet[T]{tree: tree.NewAvlTreeOf(os.tree.Comparator())}
+	cc := os.OpenCursor()
+	for cc.HasNext() {
+		k := cc.Next()
+		if !s.Contains(k) {
+			r.tree.Insert(k)
+		}
+	}
+	return r
+}
+
+func (os *orderedSet[T]) SymmetricDifference(s SetOf[T]) SetOf[T] {
+	return os.Difference(s).Union(s.Difference(os))
+}
+
+func (os *orderedSet[T]) OpenCursor() c.CursorOf[T] {
+	return os.tree.First()
+}
+
+func (os *orderedSet[T]) All() iter.Seq[T] {
+	return os.tree.All()
+}
+
+func (os *orderedSet[T]) Add(xs ...T) int {
+	n := os.tree.Size()
+	for _, k := range xs {
+		os.tree.Insert(k)
+	}
+	return int(os.tree.Size() - n)
+}
+
+func (os *orderedSet[T]) Remove(xs ...T) int {
+	var n int
+	for _, k := range xs {
+		if _, found := os.tree.Delete(k); found {
+			n++
+		}
+	}
+	return n
+}
+
+func (os *orderedSet[T]) Retain(xs ...T) int {
+	return os.RetainAll(NewSetOf(os.tree.Comparator(), xs...))
+}
+
+func (os *orderedSet[T]) AddAll(s SetOf[T]) int {
+	return os.Add(slices.Collect(s.All())...)
+}
+
+func (os *orderedSet[T]) RemoveAll(s SetOf[T]) int {
+	return os.Remove(slices.Collect(s.All())...)
+}
+
+func (os *orderedSet[T]) RetainAll(s SetOf[T]) int {
+	var doomed []T
+	for k := range os.All() {
+		if !s.Contains(k) {
+			doomed = append(doomed, k)
+		}
+	}
+	return os.Remove(doomed...)
+}
+
+func (os *orderedSet[T]) Clear() {
+	os.tree.Clear()
+}
@@ -0,0 +1,199 @@
+package set
+
+import (
+	"iter"
+
+	c "github.com/dtromb/collections"
+)
+
+// hashSet is an unordered MutableSet backed by a hash table of element
+// buckets.  Its elements must implement c.Hashable, which it uses for
+// lookups and to find equal sets; their CompareTo is used only to order
+// unequal sets of the same size, as for compareSets.
+type hashSet struct {
+	buckets map[uint64][]c.Comparable
+	size    int
+}
+
+// HashSet returns an unordered MutableSet holding the arguments.  Elements
+// added to it must implement c.Hashable as well as c.Comparable.  Contains,
+// Add and Remove take O(1) expected time, and iteration order is unspecified.
+func HashSet(cs ...c.Comparable) MutableSet {
+	hs := &hashSet{buckets: make(map[uint64][]c.Comparable)}
+	hs.Add(cs...)
+	return hs
+}
+
+func (hs *hashSet) Ordered() bool { return false }
+
+func (hs *hashSet) CompareTo(c c.Comparable) int8 {
+	return compareSets(hs, c)
+}
+
+func (hs *hashSet) Size() int { return hs.size }
+
+// find returns the bucket for x and the index of x within it, or -1.
+func (hs *hashSet) find(x c.Comparable) (uint64, int) {
+	hx := x.(c.Hashable)
+	h := hx.Hash()
+	for i, k := range hs.buckets[h] {
+		if hx.Equal(k.(c.Hashable)) {
+			return h, i
+		}
+	}
+	return h, -1
+}
+
+func (hs *hashSet) Contains(cm c.Comparable) bool {
+	_, i := hs.find(cm)
+	return i >= 0
+}
+
+func (hs *hashSet) Add(cs ...c.Comparable) int {
+	var n int
+	for _, k := range cs {
+		h, i := hs.find(k)
+		if i >= 0 {
+			hs.buckets[h][i] = k
+			continue
+		}
+		hs.buckets[h] = append(hs.buckets[h], k)
+		hs.size++
+		n++
+	}
+	return n
+}
+
+func (hs *hashSet) Remove(cs ...c.Comparable) int {
+	var n int
+	for _, k := range cs {
+		h, i := hs.find(k)
+		if i < 0 {
+			continue
+		}
+		b := hs.buckets[h]
+		last := len(b) - 1
+		b[i] = b[last]
+		b[last] = nil
+		if last == 0 {
+			delete(hs.buckets, h)
+		} else {
+			hs.buckets[h] = b[:last]
+		}
+		hs.size--
+		n++
+	}
+	return n
+}
+
+func (hs *hashSet) Retain(cs ...c.Comparable) int {
+	return hs.RetainAll(HashSet(cs...))
+}
+
+func (hs *hashSet) AddAll(s Set) int {
+	var n int
+	for k := range s.All() {
+		n += hs.Add(k)
+	}
+	return n
+}
+
+func (hs *hashSet) RemoveAll(s Set) int {
+	var n int
+	for k := range s.All() {
+		n += hs.Remove(k)
+	}
+	return n
+}
+
+func (hs *hashSet) RetainAll(s Set) int {
+	var doomed []c.Comparable
+	for k := range hs.All() {
+		if !s.Contains(k) {
+			doomed = append(doomed, k)
+		}
+	}
+	return hs.Remove(doomed...)
+}
+
+func (hs *hashSet) Clear() {
+	hs.buckets = make(map[uint64][]c.Comparable)
+	hs.size = 0
+}
+
+func (hs *hashSet) clone() *hashSet {
+	ns := &hashSet{buckets: make(map[uint64][]c.Comparable, len(hs.buckets)), size: hs.size}
+	for h, b := range hs.buckets {
+		ns.buckets[h] = append([]c.Comparable(nil), b...)
+	}
+	return ns
+}
+
+func (hs *hashSet) Union(s Set) Set {
+	ns := hs.clone()
+	ns.AddAll(s)
+	return ns
+}
+
+func (hs *hashSet) Intersection(s Set) Set {
+	ns := HashSet()
+	if s.Size() < hs.Size() {
+		for k := range s.All() {
+			if hs.Contains(k) {
+				ns.Add(k)
+			}
+		}
+		return ns
+	}
+	for k := range hs.All() {
+		if s.Contains(k) {
+			ns.Add(k)
+		}
+	}
+	return ns
+}
+
+func (hs *hashSet) Difference(s Set) Set {
+	if s.Size() < hs.Size() {
+		ns := hs.clone()
+		ns.RemoveAll(s)
+		return ns
+	}
+	ns := HashSet()
+	for k := range hs.All() {
+		if !s.Contains(k) {
+			ns.Add(k)
+		}
+	}
+	return ns
+}
+
+func (hs *hashSet) SymmetricDifference(s Set) Set {
+	ns := hs.clone()
+	for k := range s.All() {
+		if hs.Contains(k) {
+			ns.Remove(k)
+		} else {
+			ns.Add(k)
+		}
+	}
+	return ns
+}
+
+func (hs *hashSet) All() iter.Seq[c.Comparable] {
+	return func(yield func(c.Comparable) bool) {
+		for _, b := range hs.buckets {
+			for _, k := range b {
+				if !yield(k) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// OpenCursor opens a cursor over a snapshot of the elements of the set, which
+// is unaffected by later changes to it.
+func (hs *hashSet) OpenCursor() c.Cursor {
+	return c.FromSeq(hs.All())
+}
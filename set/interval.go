@@ -0,0 +1,205 @@
+package set
+
+import (
+	"iter"
+	"slices"
+	"sort"
+
+	c "github.com/dtromb/collections"
+)
+
+// Range is the half-open range [Lo, Hi) of Comparable values.  A nil Lo
+// leaves the range unbounded below, and a nil Hi unbounded above.  Half-open
+// ranges are closed under complement, and [a, b) and [b, c) together make
+// up [a, c).
+type Range struct {
+	Lo, Hi c.Comparable
+}
+
+// IntervalSet is a set of points made up of Ranges.  It holds them coalesced:
+// sorted, non-empty and with a gap between each and the next, so that
+// overlapping and adjacent ranges are merged as they are added.  The zero
+// value is an empty set.
+type IntervalSet struct {
+	ranges []Range
+}
+
+// loBefore orders two lower bounds, where nil is below every value.
+func loBefore(a, b c.Comparable) bool {
+	return a == nil && b != nil || a != nil && b != nil && a.CompareTo(b) < 0
+}
+
+// hiBefore orders two upper bounds, where nil is above every value.
+func hiBefore(a, b c.Comparable) bool {
+	return b == nil && a != nil || a != nil && b != nil && a.CompareTo(b) < 0
+}
+
+// gapBetween checks whether the points below the upper bound hi and those at
+// or above the lower bound lo leave a gap between them.
+func gapBetween(hi, lo c.Comparable) bool {
+	return hi != nil && lo != nil && hi.CompareTo(lo) < 0
+}
+
+// empty checks whether the range holds no points.
+func (r Range) empty() bool {
+	return r.Lo != nil && r.Hi != nil && r.Lo.CompareTo(r.Hi) >= 0
+}
+
+// Contains checks whether the range holds the point p.
+func (r Range) Contains(p c.Comparable) bool {
+	return (r.Lo == nil || r.Lo.CompareTo(p) <= 0) && (r.Hi == nil || p.CompareTo(r.Hi) < 0)
+}
+
+// NewIntervalSet returns an IntervalSet holding the points in the arguments.
+func NewIntervalSet(rs ...Range) *IntervalSet {
+	is := &IntervalSet{}
+	sorted := slices.Clone(rs)
+	slices.SortFunc(sorted, func(a, b Range) int {
+		switch {
+		case loBefore(a.Lo, b.Lo):
+			return -1
+		case loBefore(b.Lo, a.Lo):
+			return 1
+		}
+		return 0
+	})
+	for _, r := range sorted {
+		is.push(r)
+	}
+	return is
+}
+
+// push appends a range to the set, merging it into the last range unless
+// there is a gap between them.  The range must not start before the last.
+func (is *IntervalSet) push(r Range) {
+	if r.empty() {
+		return
+	}
+	if k := len(is.ranges); k > 0 && !gapBetween(is.ranges[k-1].Hi, r.Lo) {
+		if hiBefore(is.ranges[k-1].Hi, r.Hi) {
+			is.ranges[k-1].Hi = r.Hi
+		}
+		return
+	}
+	is.ranges = append(is.ranges, r)
+}
+
+// Len returns the number of coalesced ranges in the set.
+func (is *IntervalSet) Len() int { return len(is.ranges) }
+
+// Ranges returns the coalesced ranges of the set in ascending order.
+func (is *IntervalSet) Ranges() iter.Seq[Range] {
+	return slices.Values(is.ranges)
+}
+
+// find returns the index of the first range which ends above p.
+func (is *IntervalSet) find(p c.Comparable) int {
+	return sort.Search(len(is.ranges), func(i int) bool {
+		return is.ranges[i].Hi == nil || p.CompareTo(is.ranges[i].Hi) < 0
+	})
+}
+
+// Contains checks whether the set holds the point p, in O(ln(n)) time.
+func (is *IntervalSet) Contains(p c.Comparable) bool {
+	i := is.find(p)
+	return i < len(is.ranges) && is.ranges[i].Contains(p)
+}
+
+// Add adds the points of r to the set.  It finds the ranges r touches by
+// binary search and splices them out in favour of their union with r, so it
+// makes O(ln(n)+k) comparisons for k ranges merged.
+func (is *IntervalSet) Add(r Range) {
+	if r.empty() {
+		return
+	}
+	i := sort.Search(len(is.ranges), func(i int) bool { return !gapBetween(is.ranges[i].Hi, r.Lo) })
+	j := sort.Search(len(is.ranges), func(j int) bool { return gapBetween(r.Hi, is.ranges[j].Lo) })
+	if i < j {
+		if loBefore(is.ranges[i].Lo, r.Lo) {
+			r.Lo = is.ranges[i].Lo
+		}
+		if hiBefore(r.Hi, is.ranges[j-1].Hi) {
+			r.Hi = is.ranges[j-1].Hi
+		}
+	}
+	is.ranges = slices.Replace(is.ranges, i, j, r)
+}
+
+// Remove removes the points of r from the set, splicing out the ranges it
+// overlaps as Add does, keeping whatever of the first and last lies outside r.
+func (is *IntervalSet) Remove(r Range) {
+	if r.empty() {
+		return
+	}
+	i := sort.Search(len(is.ranges), func(i int) bool { return r.Lo == nil || hiBefore(r.Lo, is.ranges[i].Hi) })
+	j := sort.Search(len(is.ranges), func(j int) bool { return r.Hi != nil && !loBefore(is.ranges[j].Lo, r.Hi) })
+	if i >= j {
+		return
+	}
+	var keep []Range
+	if loBefore(is.ranges[i].Lo, r.Lo) {
+		keep = append(keep, Range{is.ranges[i].Lo, r.Lo})
+	}
+	if hiBefore(r.Hi, is.ranges[j-1].Hi) {
+		keep = append(keep, Range{r.Hi, is.ranges[j-1].Hi})
+	}
+	is.ranges = slices.Replace(is.ranges, i, j, keep...)
+}
+
+// Union returns the set of points in either set, in O(n+m) time.
+func (is *IntervalSet) Union(o *IntervalSet) *IntervalSet {
+	ns := &IntervalSet{}
+	i, j := 0, 0
+	for i < len(is.ranges) || j < len(o.ranges) {
+		if j == len(o.ranges) || i < len(is.ranges) && !loBefore(o.ranges[j].Lo, is.ranges[i].Lo) {
+			ns.push(is.ranges[i])
+			i++
+		} else {
+			ns.push(o.ranges[j])
+			j++
+		}
+	}
+	return ns
+}
+
+// Intersection returns the set of points in both sets, in O(n+m) time.
+func (is *IntervalSet) Intersection(o *IntervalSet) *IntervalSet {
+	ns := &IntervalSet{}
+	i, j := 0, 0
+	for i < len(is.ranges) && j < len(o.ranges) {
+		a, b := is.ranges[i], o.ranges[j]
+		r := a
+		if loBefore(a.Lo, b.Lo) {
+			r.Lo = b.Lo
+		}
+		if hiBefore(b.Hi, a.Hi) {
+			r.Hi = b.Hi
+			j++
+		} else {
+			i++
+		}
+		ns.push(r)
+	}
+	return ns
+}
+
+// Complement returns the set of points not in the set.
+func (is *IntervalSet) Complement() *IntervalSet {
+	ns := &IntervalSet{}
+	var lo c.Comparable
+	for i, r := range is.ranges {
+		if i > 0 || r.Lo != nil {
+			ns.ranges = append(ns.ranges, Range{lo, r.Lo})
+		}
+		lo = r.Hi
+	}
+	if len(is.ranges) == 0 || lo != nil {
+		ns.ranges = append(ns.ranges, Range{lo, nil})
+	}
+	return ns
+}
+
+// Difference returns the set of points in the receiver but not the argument.
+func (is *IntervalSet) Difference(o *IntervalSet) *IntervalSet {
+	return is.Intersection(o.Complement())
+}
@@ -0,0 +1,110 @@
+package set
+
+import (
+	"math/bits"
+
+	c "github.com/dtromb/collections"
+)
+
+// Flags selecting the elements kept by mergeOrdered.
+const (
+	keepLeft  = 1 << iota // elements only in the first set
+	keepRight             // elements only in the second set
+	keepBoth              // elements in both sets, taken from the first
+)
+
+// mergeOrdered walks two ordered sets together in O(n+m) time, returning in
+// order the elements selected by keep.
+func mergeOrdered(a, b Set, keep int) []c.Comparable {
+	var out []c.Comparable
+	ac, bc := a.OpenCursor(), b.OpenCursor()
+	next := func(cc c.Cursor) c.Comparable {
+		if cc.HasNext() {
+			return cc.Next()
+		}
+		return nil
+	}
+	x, y := next(ac), next(bc)
+	for x != nil && y != nil {
+		r := x.CompareTo(y)
+		switch {
+		case r < 0:
+			if keep&keepLeft != 0 {
+				out = append(out, x)
+			}
+			x = next(ac)
+		case r > 0:
+			if keep&keepRight != 0 {
+				out = append(out, y)
+			}
+			y = next(bc)
+		default:
+			if keep&keepBoth != 0 {
+				out = append(out, x)
+			}
+			x, y = next(ac), next(bc)
+		}
+	}
+	for ; x != nil && keep&keepLeft != 0; x = next(ac) {
+		out = append(out, x)
+	}
+	for ; y != nil && keep&keepRight != 0; y = next(bc) {
+		out = append(out, y)
+	}
+	return out
+}
+
+// mergeOrderedOf is mergeOrdered for cursors over values of any type, both
+// in the order of compare.
+func mergeOrderedOf[T any](ac, bc c.CursorOf[T], compare func(a, b T) int, keep int) []T {
+	var out []T
+	next := func(cc c.CursorOf[T]) (T, bool) {
+		if cc.HasNext() {
+			return cc.Next(), true
+		}
+		var none T
+		return none, false
+	}
+	x, xok := next(ac)
+	y, yok := next(bc)
+	for xok && yok {
+		r := compare(x, y)
+		switch {
+		case r < 0:
+			if keep&keepLeft != 0 {
+				out = append(out, x)
+			}
+			x, xok = next(ac)
+		case r > 0:
+			if keep&keepRight != 0 {
+				out = append(out, y)
+			}
+			y, yok = next(bc)
+		default:
+			if keep&keepBoth != 0 {
+				out = append(out, x)
+			}
+			x, xok = next(ac)
+			y, yok = next(bc)
+		}
+	}
+	for ; xok && keep&keepLeft != 0; x, xok = next(ac) {
+		out = append(out, x)
+	}
+	for ; yok && keep&keepRight != 0; y, yok = next(bc) {
+		out = append(out, y)
+	}
+	return out
+}
+
+// preferMerge decides whether an operation that visits each of n elements
+// and looks each up in a set of m should be done as a merge instead.  The
+// lookups cost about n*log2(m) comparisons against n+m for the merge.
+func preferMerge(n, m int) bool {
+	return n*bits.Len(uint(m)) >= n+m
+}
+
+// symmetricDifference is the fallback for sets which cannot be merged.
+func symmetricDifference(a, b Set) Set {
+	return a.Difference(b).Union(b.Difference(a))
+}
@@ -0,0 +1,320 @@
+package set
+
+import (
+	"iter"
+
+	c "github.com/dtromb/collections"
+	tree "github.com/dtromb/collections/tree"
+)
+
+// Multiset is an ordered collection in which each element may occur more
+// than once; it holds a count of each element rather than the element
+// itself.  Size is the total of the counts, and Distinct the set of elements
+// with a count of at least one.  Add and Remove change the count of an
+// element by n, which must not be negative: Add returns the new count, and
+// Remove the number of copies removed, which is n unless fewer were held.
+//
+// The bag algebra takes each element with the larger of its counts in the two
+// multisets for Union, their total for Sum, the smaller for Intersection, and
+// the count in the receiver less that in the argument (but not below zero) for
+// Difference.  Multisets are ordered first by size and then lexicographically
+// by their elements in ascending order, each repeated by its count.
+type Multiset interface {
+	c.Comparable
+	Size() int
+	Count(x c.Comparable) int
+	Contains(x c.Comparable) bool
+	Distinct() Set
+	Add(x c.Comparable, n int) int
+	Remove(x c.Comparable, n int) int
+	Union(m Multiset) Multiset
+	Sum(m Multiset) Multiset
+	Intersection(m Multiset) Multiset
+	Difference(m Multiset) Multiset
+	OpenCursor() c.Cursor
+	OpenCountCursor() CountCursor
+	All() iter.Seq[c.Comparable]
+	Counts() iter.Seq2[c.Comparable, int]
+}
+
+// CountCursor is an iterator over the distinct elements of a Multiset in
+// ascending order, returning each element along with its count.  It moves as
+// a cursor over the elements of Distinct does.
+type CountCursor interface {
+	HasNext() bool
+	HasPrev() bool
+	Next() (c.Comparable, int)
+	Prev() (c.Comparable, int)
+}
+
+// treeMultiset is a Multiset backed by an OrderedMap from each element to its
+// count.
+type treeMultiset struct {
+	counts *tree.OrderedMap
+	impl   []tree.TreeImplementation
+	size   int
+}
+
+type countCursor struct {
+	ec tree.EntryCursor
+}
+
+// multisetCursor repeats each element by its count.  It sits in the gap after
+// the first i of the n copies of k, or before all elements if k is nil.  The
+// count of each element is read when the cursor reaches it.
+type multisetCursor struct {
+	ms   *treeMultiset
+	k    c.Comparable
+	i, n int
+}
+
+// NewMultiset creates an empty Multiset backed by a tree of the given
+// implementation (see tree.NewTree).
+func NewMultiset(impl ...tree.TreeImplementation) Multiset {
+	return &treeMultiset{counts: tree.NewOrderedMap(impl...), impl: impl}
+}
+
+func (ms *treeMultiset) empty() *treeMultiset {
+	return NewMultiset(ms.impl...).(*treeMultiset)
+}
+
+func (ms *treeMultiset) CompareTo(cm c.Comparable) int8 {
+	om, ok := cm.(Multiset)
+	if !ok {
+		return 1
+	}
+	if ms.Size() != om.Size() {
+		if ms.Size() < om.Size() {
+			return -1
+		}
+		return 1
+	}
+	cc, oc := ms.OpenCursor(), om.OpenCursor()
+	for cc.HasNext() {
+		if r := cc.Next().CompareTo(oc.Next()); r != 0 {
+			return r
+		}
+	}
+	return 0
+}
+
+func (ms *treeMultiset) Size() int { return ms.size }
+
+func (ms *treeMultiset) Count(x c.Comparable) int {
+	if v, ok := ms.counts.Get(x); ok {
+		return v.(int)
+	}
+	return 0
+}
+
+func (ms *treeMultiset) Contains(x c.Comparable) bool {
+	return ms.counts.Has(x)
+}
+
+func (ms *treeMultiset) Distinct() Set {
+	return TreeSet(tree.BuildFromSorted(ms.counts.Keys()))
+}
+
+func (ms *treeMultiset) Add(x c.Comparable, n int) int {
+	if n < 0 {
+		panic("Multiset.Add() count must not be negative")
+	}
+	k := ms.Count(x) + n
+	if k > 0 {
+		ms.counts.Put(x, k)
+	}
+	ms.size += n
+	return k
+}
+
+func (ms *treeMultiset) Remove(x c.Comparable, n int) int {
+	if n < 0 {
+		panic("Multiset.Remove() count must not be negative")
+	}
+	k := ms.Count(x)
+	if n >= k {
+		n = k
+		ms.counts.Remove(x)
+	} else {
+		ms.counts.Put(x, k-n)
+	}
+	ms.size -= n
+	return n
+}
+
+// merge walks two multisets together in ascending order, building one in
+// which each element has the count op gives for its counts in the two.
+func (ms *treeMultiset) merge(m Multiset, op func(a, b int) int) Multiset {
+	out := ms.empty()
+	ac, bc := ms.OpenCountCursor(), m.OpenCountCursor()
+	next := func(cc CountCursor) (c.Comparable, int) {
+		if cc.HasNext() {
+			return cc.Next()
+		}
+		return nil, 0
+	}
+	x, xn := next(ac)
+	y, yn := next(bc)
+	for x != nil || y != nil {
+		var r int8
+		switch {
+		case x == nil:
+			r = 1
+		case y == nil:
+			r = -1
+		default:
+			r = x.CompareTo(y)
+		}
+		switch {
+		case r < 0:
+			out.Add(x, op(xn, 0))
+			x, xn = next(ac)
+		case r > 0:
+			out.Add(y, op(0, yn))
+			y, yn = next(bc)
+		default:
+			out.Add(x, op(xn, yn))
+			x, xn = next(ac)
+			y, yn = next(bc)
+		}
+	}
+	return out
+}
+
+func (ms *treeMultiset) Union(m Multiset) Multiset {
+	return ms.merge(m, func(a, b int) int { return max(a, b) })
+}
+
+func (ms *treeMultiset) Sum(m Multiset) Multiset {
+	return ms.merge(m, func(a, b int) int { return a + b })
+}
+
+func (ms *treeMultiset) Intersection(m Multiset) Multiset {
+	return ms.merge(m, func(a, b int) int { return min(a, b) })
+}
+
+func (ms *treeMultiset) Difference(m Multiset) Multiset {
+	return ms.merge(m, func(a, b int) int { return max(a-b, 0) })
+}
+
+// OpenCursor opens a cursor positioned before the smallest element, which
+// returns each element as many times as it occurs.
+func (ms *treeMultiset) OpenCursor() c.Cursor {
+	return &multisetCursor{ms: ms}
+}
+
+// OpenCountCursor opens a cursor positioned before the smallest element,
+// which returns each distinct element once along with its count.
+func (ms *treeMultiset) OpenCountCursor() CountCursor {
+	return &countCursor{ec: ms.counts.First()}
+}
+
+func (ms *treeMultiset) All() iter.Seq[c.Comparable] {
+	return func(yield func(c.Comparable) bool) {
+		for k, n := range ms.Counts() {
+			for ; n > 0; n-- {
+				if !yield(k) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (ms *treeMultiset) Counts() iter.Seq2[c.Comparable, int] {
+	return func(yield func(c.Comparable, int) bool) {
+		cc := ms.OpenCountCursor()
+		for cc.HasNext() {
+			if !yield(cc.Next()) {
+				return
+			}
+		}
+	}
+}
+
+func (cc *countCursor) HasNext() bool { return cc.ec.HasNext() }
+
+func (cc *countCursor) HasPrev() bool { return cc.ec.HasPrev() }
+
+func (cc *countCursor) Next() (c.Comparable, int) {
+	k, v := cc.ec.Next()
+	if k == nil {
+		return nil, 0
+	}
+	return k, v.(int)
+}
+
+func (cc *countCursor) Prev() (c.Comparable, int) {
+	k, v := cc.ec.Prev()
+	if k == nil {
+		return nil, 0
+	}
+	return k, v.(int)
+}
+
+// after returns the distinct element after k, or the smallest if k is nil,
+// along with its count.
+func (mc *multisetCursor) after() (c.Comparable, int) {
+	if mc.k == nil {
+		cc := mc.ms.OpenCountCursor()
+		return cc.Next()
+	}
+	k, v, _ := mc.ms.counts.Lookup(c.GT, mc.k)
+	if k == nil {
+		return nil, 0
+	}
+	return k, v.(int)
+}
+
+// before returns the distinct element before k, or nil if k is nil, along
+// with its count.
+func (mc *multisetCursor) before() (c.Comparable, int) {
+	if mc.k == nil {
+		return nil, 0
+	}
+	k, v, _ := mc.ms.counts.Lookup(c.LT, mc.k)
+	if k == nil {
+		return nil, 0
+	}
+	return k, v.(int)
+}
+
+func (mc *multisetCursor) HasNext() bool {
+	if mc.k != nil && mc.i < mc.n {
+		return true
+	}
+	k, _ := mc.after()
+	return k != nil
+}
+
+func (mc *multisetCursor) HasPrev() bool {
+	if mc.i > 0 {
+		return true
+	}
+	k, _ := mc.before()
+	return k != nil
+}
+
+func (mc *multisetCursor) Next() c.Comparable {
+	if mc.k == nil || mc.i == mc.n {
+		k, n := mc.after()
+		if k == nil {
+			return nil
+		}
+		mc.k, mc.n, mc.i = k, n, 0
+	}
+	mc.i++
+	return mc.k
+}
+
+func (mc *multisetCursor) Prev() c.Comparable {
+	if mc.i == 0 {
+		k, n := mc.before()
+		if k == nil {
+			return nil
+		}
+		mc.k, mc.n, mc.i = k, n, n
+	}
+	mc.i--
+	return mc.k
+}
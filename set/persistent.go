@@ -0,0 +1,154 @@
+package set
+
+import "iter"
+import c "github.com/dtromb/collections"
+import tree "github.com/dtromb/collections/tree"
+
+// PersistentSet is an ordered, truly immutable Set.  With and Without return
+// new sets sharing all but O(ln(n)) of their structure with the receiver, so
+// sets derived from one another are cheap to make and safe to hand out.
+type PersistentSet interface {
+	Set
+	With(cs ...c.Comparable) PersistentSet
+	Without(cs ...c.Comparable) PersistentSet
+}
+
+type persistentSet struct {
+	tree *tree.PersistentAvlTree
+}
+
+// Persistent returns a PersistentSet holding the arguments.
+func Persistent(cs ...c.Comparable) PersistentSet {
+	ps := &persistentSet{tree: &tree.PersistentAvlTree{}}
+	return ps.With(cs...)
+}
+
+func (ps *persistentSet) Ordered() bool { return true }
+
+func (ps *persistentSet) CompareTo(c c.Comparable) int8 {
+	return compareSets(ps, c)
+}
+
+func (ps *persistentSet) Size() int { return int(ps.tree.Size()) }
+
+func (ps *persistentSet) Contains(cm c.Comparable) bool {
+	return ps.tree.Has(cm)
+}
+
+func (ps *persistentSet) With(cs ...c.Comparable) PersistentSet {
+	nt := ps.tree
+	for _, k := range cs {
+		nt, _ = nt.Insert(k)
+	}
+	return &persistentSet{tree: nt}
+}
+
+func (ps *persistentSet) Without(cs ...c.Comparable) PersistentSet {
+	nt := ps.tree
+	for _, k := range cs {
+		nt, _, _ = nt.Delete(k)
+	}
+	return &persistentSet{tree: nt}
+}
+
+// merged returns the persistent set of the elements of ps and s selected by
+// keep, if s is ordered.  Otherwise it returns nil.
+func (ps *persistentSet) merged(s Set, keep int) Set {
+	if !s.Ordered() {
+		return nil
+	}
+	return &persistentSet{tree: tree.BuildPersistentFromSortedSlice(mergeOrdered(ps, s, keep))}
+}
+
+// mergeCheaper checks whether an operation between ps and s whose
+// alternative costs a lookup or update per element of the smaller set should
+// be done as a merge instead.
+func (ps *persistentSet) mergeCheaper(s Set) bool {
+	n, m := ps.Size(), s.Size()
+	if n > m {
+		n, m = m, n
+	}
+	return preferMerge(n, m)
+}
+
+func (ps *persistentSet) Union(s Set) Set {
+	if ps.mergeCheaper(s) {
+		if r := ps.merged(s, keepLeft|keepRight|keepBoth); r != nil {
+			return r
+		}
+	}
+	base, other := ps, s
+	if pso, ok := s.(*persistentSet); ok && pso.Size() > ps.Size() {
+		base, other = pso, ps
+	}
+	nt := base.tree
+	oc := other.OpenCursor()
+	for oc.HasNext() {
+		k := oc.Next()
+		if !nt.Has(k) {
+			nt, _ = nt.Insert(k)
+		}
+	}
+	return &persistentSet{tree: nt}
+}
+
+func (ps *persistentSet) Intersection(s Set) Set {
+	if ps.mergeCheaper(s) {
+		if r := ps.merged(s, keepBoth); r != nil {
+			return r
+		}
+	}
+	var small, large Set = ps, s
+	if s.Size() < ps.Size() {
+		small, large = s, ps
+	}
+	nt := &tree.PersistentAvlTree{}
+	sc := small.OpenCursor()
+	for sc.HasNext() {
+		k := sc.Next()
+		if large.Contains(k) {
+			nt, _ = nt.Insert(k)
+		}
+	}
+	return &persistentSet{tree: nt}
+}
+
+func (ps *persistentSet) Difference(s Set) Set {
+	if ps.mergeCheaper(s) {
+		if r := ps.merged(s, keepLeft); r != nil {
+			return r
+		}
+	}
+	if s.Size() < ps.Size() {
+		nt := ps.tree
+		sc := s.OpenCursor()
+		for sc.HasNext() {
+			nt, _, _ = nt.Delete(sc.Next())
+		}
+		return &persistentSet{tree: nt}
+	}
+	nt := &tree.PersistentAvlTree{}
+	c := ps.OpenCursor()
+	for c.HasNext() {
+		k := c.Next()
+		if !s.Contains(k) {
+			nt, _ = nt.Insert(k)
+		}
+	}
+	return &persistentSet{tree: nt}
+}
+
+func (ps *persistentSet) SymmetricDifference(s Set) Set {
+	if r := ps.merged(s, keepLeft|keepRight); r != nil {
+		return r
+	}
+	return symmetricDifference(ps, s)
+}
+
+func (ps *persistentSet) OpenCursor() c.Cursor {
+	return ps.tree.First()
+}
+
+func (ps *persistentSet) All() iter.Seq[c.Comparable] {
+	return c.Values(ps.tree.First())
+}
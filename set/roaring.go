@@ -0,0 +1,912 @@
+package set
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"iter"
+	"math/bits"
+	"sort"
+
+	c "github.com/dtromb/collections"
+)
+
+// Roaring serialization constants, from the format specification at
+// https://github.com/RoaringBitmap/RoaringFormatSpec.
+const (
+	roaringCookieNoRuns   = 12346
+	roaringCookie         = 12347
+	roaringNoOffsetThresh = 4
+)
+
+// arrayMax is the largest cardinality held in an array container.
+const arrayMax = 4096
+
+// container holds the low 16 bits of the elements of a RoaringBitmap that
+// share their high 16 bits.  Mutating methods may return a container of a
+// different kind, which replaces the receiver.
+type container interface {
+	card() int
+	contains(x uint16) bool
+	add(x uint16) (container, bool)
+	remove(x uint16) (container, bool)
+	// next returns the smallest element >= x, or -1 if there is none.
+	next(x int) int
+	// prev returns the largest element <= x, or -1 if there is none.
+	prev(x int) int
+	each(yield func(uint16) bool) bool
+	// bitmap returns the contents as a new bitmap container.
+	bitmap() *bitmapContainer
+	clone() container
+}
+
+// arrayContainer is a sorted array of the elements, for sparse containers.
+type arrayContainer []uint16
+
+// bitmapContainer holds one bit per possible element, for dense containers.
+type bitmapContainer struct {
+	words [1024]uint64
+	n     int
+}
+
+// runContainer is a sorted list of the runs of consecutive elements.
+type runContainer []run16
+
+type run16 struct {
+	start, last uint16
+}
+
+func (a arrayContainer) card() int { return len(a) }
+
+func (a arrayContainer) search(x int) int {
+	return sort.Search(len(a), func(i int) bool { return int(a[i]) >= x })
+}
+
+func (a arrayContainer) contains(x uint16) bool {
+	i := a.search(int(x))
+	return i < len(a) && a[i] == x
+}
+
+func (a arrayContainer) add(x uint16) (container, bool) {
+	i := a.search(int(x))
+	if i < len(a) && a[i] == x {
+		return a, false
+	}
+	if len(a) == arrayMax {
+		b := a.bitmap()
+		b.add(x)
+		return b, true
+	}
+	a = append(a, 0)
+	copy(a[i+1:], a[i:])
+	a[i] = x
+	return a, true
+}
+
+func (a arrayContainer) remove(x uint16) (container, bool) {
+	i := a.search(int(x))
+	if i == len(a) || a[i] != x {
+		return a, false
+	}
+	copy(a[i:], a[i+1:])
+	return a[:len(a)-1], true
+}
+
+func (a arrayContainer) next(x int) int {
+	if i := a.search(x); i < len(a) {
+		return int(a[i])
+	}
+	return -1
+}
+
+func (a arrayContainer) prev(x int) int {
+	if i := a.search(x+1) - 1; i >= 0 {
+		return int(a[i])
+	}
+	return -1
+}
+
+func (a arrayContainer) each(yield func(uint16) bool) bool {
+	for _, x := range a {
+		if !yield(x) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a arrayContainer) bitmap() *bitmapContainer {
+	b := &bitmapContainer{}
+	for _, x := range a {
+		b.add(x)
+	}
+	return b
+}
+
+func (a arrayContainer) clone() container {
+	return append(arrayContainer(nil), a...)
+}
+
+func (b *bitmapContainer) card() int { return b.n }
+
+func (b *bitmapContainer) contains(x uint16) bool {
+	return b.words[x>>6]&(1<<(x&63)) != 0
+}
+
+func (b *bitmapContainer) add(x uint16) (container, bool) {
+	if b.contains(x) {
+		return b, false
+	}
+	b.words[x>>6] |= 1 << (x & 63)
+	b.n++
+	return b, true
+}
+
+func (b *bitmapContainer) remove(x uint16) (container, bool) {
+	if !b.contains(x) {
+		return b, false
+	}
+	b.words[x>>6] &^= 1 << (x & 63)
+	b.n--
+	if b.n <= arrayMax {
+		return b.array(), true
+	}
+	return b, true
+}
+
+func (b *bitmapContainer) next(x int) int {
+	w := x >> 6
+	if w >= len(b.words) {
+		return -1
+	}
+	word := b.words[w] >> (x & 63) << (x & 63)
+	for {
+		if word != 0 {
+			return w<<6 + bits.TrailingZeros64(word)
+		}
+		w++
+		if w == len(b.words) {
+			return -1
+		}
+		word = b.words[w]
+	}
+}
+
+func (b *bitmapContainer) prev(x int) int {
+	if x < 0 {
+		return -1
+	}
+	w := x >> 6
+	word := b.words[w] << (63 - x&63) >> (63 - x&63)
+	for {
+		if word != 0 {
+			return w<<6 + 63 - bits.LeadingZeros64(word)
+		}
+		w--
+		if w < 0 {
+			return -1
+		}
+		word = b.words[w]
+	}
+}
+
+func (b *bitmapContainer) each(yield func(uint16) bool) bool {
+	for w, word := range b.words {
+		for word != 0 {
+			if !yield(uint16(w<<6 + bits.TrailingZeros64(word))) {
+				return false
+			}
+			word &= word - 1
+		}
+	}
+	return true
+}
+
+func (b *bitmapContainer) bitmap() *bitmapContainer {
+	nb := *b
+	return &nb
+}
+
+func (b *bitmapContainer) clone() container {
+	return b.bitmap()
+}
+
+// array returns the contents as an array container.
+func (b *bitmapContainer) array() arrayContainer {
+	a := make(arrayContainer, 0, b.n)
+	b.each(func(x uint16) bool {
+		a = append(a, x)
+		return true
+	})
+	return a
+}
+
+// recount recomputes the cardinality after the words have been changed
+// directly, returning the smallest container kind which holds the contents,
+// or nil if it is empty.
+func (b *bitmapContainer) recount() container {
+	b.n = 0
+	for _, w := range b.words {
+		b.n += bits.OnesCount64(w)
+	}
+	switch {
+	case b.n == 0:
+		return nil
+	case b.n <= arrayMax:
+		return b.array()
+	}
+	return b
+}
+
+func (r runContainer) card() int {
+	var n int
+	for _, rn := range r {
+		n += int(rn.last-rn.start) + 1
+	}
+	return n
+}
+
+func (r runContainer) contains(x uint16) bool {
+	i := sort.Search(len(r), func(i int) bool { return r[i].last >= x })
+	return i < len(r) && r[i].start <= x
+}
+
+// unpack returns the contents as an array or bitmap container, which run
+// containers become when they are changed.
+func (r runContainer) unpack() container {
+	if r.card() <= arrayMax {
+		var a arrayContainer
+		r.each(func(x uint16) bool {
+			a = append(a, x)
+			return true
+		})
+		return a
+	}
+	return r.bitmap()
+}
+
+func (r runContainer) add(x uint16) (container, bool) {
+	if r.contains(x) {
+		return r, false
+	}
+	return r.unpack().add(x)
+}
+
+func (r runContainer) remove(x uint16) (container, bool) {
+	if !r.contains(x) {
+		return r, false
+	}
+	return r.unpack().remove(x)
+}
+
+func (r runContainer) next(x int) int {
+	i := sort.Search(len(r), func(i int) bool { return int(r[i].last) >= x })
+	if i == len(r) {
+		return -1
+	}
+	return max(x, int(r[i].start))
+}
+
+func (r runContainer) prev(x int) int {
+	i := sort.Search(len(r), func(i int) bool { return int(r[i].start) > x }) - 1
+	if i < 0 {
+		return -1
+	}
+	return min(x, int(r[i].last))
+}
+
+func (r runContainer) each(yield func(uint16) bool) bool {
+	for _, rn := range r {
+		for x := int(rn.start); x <= int(rn.last); x++ {
+			if !yield(uint16(x)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (r runContainer) bitmap() *bitmapContainer {
+	b := &bitmapContainer{}
+	r.each(func(x uint16) bool {
+		b.add(x)
+		return true
+	})
+	return b
+}
+
+func (r runContainer) clone() container {
+	return append(runContainer(nil), r...)
+}
+
+// runsOf returns the contents of a container as a run container.
+func runsOf(ct container) runContainer {
+	var r runContainer
+	ct.each(func(x uint16) bool {
+		if k := len(r); k > 0 && int(r[k-1].last)+1 == int(x) {
+			r[k-1].last = x
+		} else {
+			r = append(r, run16{x, x})
+		}
+		return true
+	})
+	return r
+}
+
+// bitmapOp applies op to the words of the bitmaps of two containers.
+func bitmapOp(a, b container, op func(x, y uint64) uint64) container {
+	ab := a.bitmap()
+	bb, ok := b.(*bitmapContainer)
+	if !ok {
+		bb = b.bitmap()
+	}
+	for i := range ab.words {
+		ab.words[i] = op(ab.words[i], bb.words[i])
+	}
+	return ab.recount()
+}
+
+// filter returns the elements of a which are in b if keep is set, and those
+// which are not otherwise.
+func filter(a arrayContainer, b container, keep bool) container {
+	var out arrayContainer
+	for _, x := range a {
+		if b.contains(x) == keep {
+			out = append(out, x)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func orContainers(a, b container) container {
+	aa, ok1 := a.(arrayContainer)
+	ba, ok2 := b.(arrayContainer)
+	if ok1 && ok2 && len(aa)+len(ba) <= arrayMax {
+		out := make(arrayContainer, 0, len(aa)+len(ba))
+		i, j := 0, 0
+		for i < len(aa) && j < len(ba) {
+			switch {
+			case aa[i] < ba[j]:
+				out = append(out, aa[i])
+				i++
+			case aa[i] > ba[j]:
+				out = append(out, ba[j])
+				j++
+			default:
+				out = append(out, aa[i])
+				i++
+				j++
+			}
+		}
+		out = append(out, aa[i:]...)
+		return append(out, ba[j:]...)
+	}
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x | y })
+}
+
+func andContainers(a, b container) container {
+	if aa, ok := a.(arrayContainer); ok {
+		return filter(aa, b, true)
+	}
+	if ba, ok := b.(arrayContainer); ok {
+		return filter(ba, a, true)
+	}
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x & y })
+}
+
+func andNotContainers(a, b container) container {
+	if aa, ok := a.(arrayContainer); ok {
+		return filter(aa, b, false)
+	}
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x &^ y })
+}
+
+func xorContainers(a, b container) container {
+	return bitmapOp(a, b, func(x, y uint64) uint64 { return x ^ y })
+}
+
+// RoaringBitmap is a compressed ordered MutableSet of Int elements in the
+// range [0, 2^32).  Elements are grouped by their high 16 bits into
+// containers, each of which holds the low 16 bits as a sorted array, a
+// bitmap or a list of runs, whichever suits its contents.  Operations between
+// two RoaringBitmaps work a container at a time, and those between bitmap
+// containers a word at a time.  The zero value is an empty set.
+type RoaringBitmap struct {
+	keys  []uint16
+	conts []container
+}
+
+// roaringCursor sits in the gap before element pos.
+type roaringCursor struct {
+	rb  *RoaringBitmap
+	pos int64
+}
+
+// NewRoaringBitmap returns a RoaringBitmap holding the arguments, which must
+// be in the range [0, 2^32).
+func NewRoaringBitmap(xs ...int) *RoaringBitmap {
+	rb := &RoaringBitmap{}
+	for _, x := range xs {
+		rb.set(x)
+	}
+	return rb
+}
+
+// search returns the index of the first container with a key >= k.
+func (rb *RoaringBitmap) search(k int) int {
+	return sort.Search(len(rb.keys), func(i int) bool { return int(rb.keys[i]) >= k })
+}
+
+// set adds x to the set, returning whether it was absent.
+func (rb *RoaringBitmap) set(x int) bool {
+	if x < 0 || int64(x) >= 1<<32 {
+		panic("RoaringBitmap elements must be in [0, 2^32)")
+	}
+	k := x >> 16
+	i := rb.search(k)
+	if i == len(rb.keys) || int(rb.keys[i]) != k {
+		rb.keys = append(rb.keys, 0)
+		copy(rb.keys[i+1:], rb.keys[i:])
+		rb.keys[i] = uint16(k)
+		rb.conts = append(rb.conts, nil)
+		copy(rb.conts[i+1:], rb.conts[i:])
+		rb.conts[i] = arrayContainer{uint16(x)}
+		return true
+	}
+	ct, ok := rb.conts[i].add(uint16(x))
+	rb.conts[i] = ct
+	return ok
+}
+
+// clear removes x from the set, returning whether it was present.
+func (rb *RoaringBitmap) clear(x int) bool {
+	if x < 0 || int64(x) >= 1<<32 {
+		return false
+	}
+	k := x >> 16
+	i := rb.search(k)
+	if i == len(rb.keys) || int(rb.keys[i]) != k {
+		return false
+	}
+	ct, ok := rb.conts[i].remove(uint16(x))
+	if ct.card() == 0 {
+		rb.keys = append(rb.keys[:i], rb.keys[i+1:]...)
+		rb.conts = append(rb.conts[:i], rb.conts[i+1:]...)
+	} else {
+		rb.conts[i] = ct
+	}
+	return ok
+}
+
+// next returns the smallest element >= x, or -1 if there is none.
+func (rb *RoaringBitmap) next(x int64) int64 {
+	x = max(x, 0)
+	k := int(x >> 16)
+	for i := rb.search(k); i < len(rb.keys); i++ {
+		lo := 0
+		if int(rb.keys[i]) == k {
+			lo = int(x & 0xffff)
+		}
+		if v := rb.conts[i].next(lo); v >= 0 {
+			return int64(rb.keys[i])<<16 | int64(v)
+		}
+	}
+	return -1
+}
+
+// prev returns the largest element <= x, or -1 if there is none.
+func (rb *RoaringBitmap) prev(x int64) int64 {
+	if x < 0 {
+		return -1
+	}
+	x = min(x, 1<<32-1)
+	k := int(x >> 16)
+	for i := rb.search(k+1) - 1; i >= 0; i-- {
+		lo := 0xffff
+		if int(rb.keys[i]) == k {
+			lo = int(x & 0xffff)
+		}
+		if v := rb.conts[i].prev(lo); v >= 0 {
+			return int64(rb.keys[i])<<16 | int64(v)
+		}
+	}
+	return -1
+}
+
+// RunOptimize converts each container to whichever of the array, bitmap and
+// run kinds takes the least space when serialized.
+func (rb *RoaringBitmap) RunOptimize() {
+	for i, ct := range rb.conts {
+		runs := runsOf(ct)
+		n := ct.card()
+		size := 8192
+		if n <= arrayMax {
+			size = 2 * n
+		}
+		switch {
+		case 2+4*len(runs) < size:
+			rb.conts[i] = runs
+		case n <= arrayMax:
+			if _, ok := ct.(arrayContainer); !ok {
+				rb.conts[i] = ct.bitmap().array()
+			}
+		default:
+			rb.conts[i] = ct.bitmap()
+		}
+	}
+}
+
+func (rb *RoaringBitmap) Ordered() bool { return true }
+
+func (rb *RoaringBitmap) CompareTo(c c.Comparable) int8 {
+	return compareSets(rb, c)
+}
+
+func (rb *RoaringBitmap) Size() int {
+	var n int
+	for _, ct := range rb.conts {
+		n += ct.card()
+	}
+	return n
+}
+
+func (rb *RoaringBitmap) Contains(cm c.Comparable) bool {
+	i, ok := cm.(Int)
+	if !ok || i < 0 || int64(i) >= 1<<32 {
+		return false
+	}
+	k := int(i >> 16)
+	j := rb.search(k)
+	return j < len(rb.keys) && int(rb.keys[j]) == k && rb.conts[j].contains(uint16(i))
+}
+
+func (rb *RoaringBitmap) Add(cs ...c.Comparable) int {
+	var n int
+	for _, k := range cs {
+		if rb.set(int(k.(Int))) {
+			n++
+		}
+	}
+	return n
+}
+
+func (rb *RoaringBitmap) Remove(cs ...c.Comparable) int {
+	var n int
+	for _, k := range cs {
+		if i, ok := k.(Int); ok && rb.clear(int(i)) {
+			n++
+		}
+	}
+	return n
+}
+
+func (rb *RoaringBitmap) Retain(cs ...c.Comparable) int {
+	keep := &RoaringBitmap{}
+	keep.Add(cs...)
+	return rb.RetainAll(keep)
+}
+
+// roaringOf returns s as a RoaringBitmap, converting it if it is some other
+// kind of set.  Elements of other sets which cannot be held in a
+// RoaringBitmap panic if strict is set, and are otherwise ignored.
+func roaringOf(s Set, strict bool) *RoaringBitmap {
+	if rb, ok := s.(*RoaringBitmap); ok {
+		return rb
+	}
+	rb := &RoaringBitmap{}
+	for k := range s.All() {
+		if i, ok := k.(Int); ok && i >= 0 && int64(i) < 1<<32 {
+			rb.set(int(i))
+		} else if strict {
+			panic("RoaringBitmap elements must be Ints in [0, 2^32)")
+		}
+	}
+	return rb
+}
+
+// roaringMerge walks the containers of two sets together by key, building a
+// set from the containers selected by keep, as for mergeOrdered.  Those with
+// keys in one set only are copied, and op combines those in both; a nil
+// result is dropped.
+func roaringMerge(a, b *RoaringBitmap, keep int, op func(x, y container) container) *RoaringBitmap {
+	out := &RoaringBitmap{}
+	emit := func(k uint16, ct container) {
+		if ct != nil {
+			out.keys = append(out.keys, k)
+			out.conts = append(out.conts, ct)
+		}
+	}
+	i, j := 0, 0
+	for i < len(a.keys) || j < len(b.keys) {
+		switch {
+		case j == len(b.keys) || i < len(a.keys) && a.keys[i] < b.keys[j]:
+			if keep&keepLeft != 0 {
+				emit(a.keys[i], a.conts[i].clone())
+			}
+			i++
+		case i == len(a.keys) || b.keys[j] < a.keys[i]:
+			if keep&keepRight != 0 {
+				emit(b.keys[j], b.conts[j].clone())
+			}
+			j++
+		default:
+			if keep&keepBoth != 0 {
+				emit(a.keys[i], op(a.conts[i], b.conts[j]))
+			}
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// replace sets the contents of the receiver to those of ns, returning the
+// number of elements changed.
+func (rb *RoaringBitmap) replace(ns *RoaringBitmap, n int) int {
+	*rb = *ns
+	return n
+}
+
+func (rb *RoaringBitmap) AddAll(s Set) int {
+	ns := rb.Union(s).(*RoaringBitmap)
+	return rb.replace(ns, ns.Size()-rb.Size())
+}
+
+func (rb *RoaringBitmap) RemoveAll(s Set) int {
+	ns := roaringMerge(rb, roaringOf(s, false), keepLeft|keepBoth, andNotContainers)
+	return rb.replace(ns, rb.Size()-ns.Size())
+}
+
+func (rb *RoaringBitmap) RetainAll(s Set) int {
+	ns := roaringMerge(rb, roaringOf(s, false), keepBoth, andContainers)
+	return rb.replace(ns, rb.Size()-ns.Size())
+}
+
+func (rb *RoaringBitmap) Clear() {
+	rb.keys, rb.conts = nil, nil
+}
+
+// Clone returns a copy of the set.
+func (rb *RoaringBitmap) Clone() *RoaringBitmap {
+	return roaringMerge(rb, &RoaringBitmap{}, keepLeft, nil)
+}
+
+func (rb *RoaringBitmap) Union(s Set) Set {
+	return roaringMerge(rb, roaringOf(s, true), keepLeft|keepRight|keepBoth, orContainers)
+}
+
+func (rb *RoaringBitmap) Intersection(s Set) Set {
+	return roaringMerge(rb, roaringOf(s, false), keepBoth, andContainers)
+}
+
+func (rb *RoaringBitmap) Difference(s Set) Set {
+	return roaringMerge(rb, roaringOf(s, false), keepLeft|keepBoth, andNotContainers)
+}
+
+func (rb *RoaringBitmap) SymmetricDifference(s Set) Set {
+	return roaringMerge(rb, roaringOf(s, true), keepLeft|keepRight|keepBoth, xorContainers)
+}
+
+func (rb *RoaringBitmap) All() iter.Seq[c.Comparable] {
+	return func(yield func(c.Comparable) bool) {
+		for i, ct := range rb.conts {
+			hi := int(rb.keys[i]) << 16
+			if !ct.each(func(x uint16) bool { return yield(Int(hi | int(x))) }) {
+				return
+			}
+		}
+	}
+}
+
+// OpenCursor opens a cursor positioned before the smallest element.  It
+// follows later changes to the set.
+func (rb *RoaringBitmap) OpenCursor() c.Cursor {
+	return &roaringCursor{rb: rb}
+}
+
+func (rc *roaringCursor) HasNext() bool { return rc.rb.next(rc.pos) >= 0 }
+
+func (rc *roaringCursor) HasPrev() bool { return rc.rb.prev(rc.pos-1) >= 0 }
+
+func (rc *roaringCursor) Next() c.Comparable {
+	i := rc.rb.next(rc.pos)
+	if i < 0 {
+		return nil
+	}
+	rc.pos = i + 1
+	return Int(i)
+}
+
+func (rc *roaringCursor) Prev() c.Comparable {
+	i := rc.rb.prev(rc.pos - 1)
+	if i < 0 {
+		return nil
+	}
+	rc.pos = i
+	return Int(i)
+}
+
+// WriteTo writes the set to w in the portable Roaring serialization format,
+// returning the number of bytes written.  Containers are written in their
+// current kinds; call RunOptimize first to use run containers where they
+// are smaller.
+func (rb *RoaringBitmap) WriteTo(w io.Writer) (int64, error) {
+	le := binary.LittleEndian
+	n := len(rb.keys)
+	var runs []byte
+	for i, ct := range rb.conts {
+		if _, ok := ct.(runContainer); ok {
+			if runs == nil {
+				runs = make([]byte, (n+7)/8)
+			}
+			runs[i/8] |= 1 << (i % 8)
+		}
+	}
+	var buf []byte
+	if runs != nil {
+		buf = le.AppendUint32(buf, uint32(roaringCookie|(n-1)<<16))
+		buf = append(buf, runs...)
+	} else {
+		buf = le.AppendUint32(buf, roaringCookieNoRuns)
+		buf = le.AppendUint32(buf, uint32(n))
+	}
+	for i, ct := range rb.conts {
+		buf = le.AppendUint16(buf, rb.keys[i])
+		buf = le.AppendUint16(buf, uint16(ct.card()-1))
+	}
+	if runs == nil || n >= roaringNoOffsetThresh {
+		offset := len(buf) + 4*n
+		for _, ct := range rb.conts {
+			buf = le.AppendUint32(buf, uint32(offset))
+			switch ct := ct.(type) {
+			case arrayContainer:
+				offset += 2 * len(ct)
+			case *bitmapContainer:
+				offset += 8192
+			case runContainer:
+				offset += 2 + 4*len(ct)
+			}
+		}
+	}
+	for _, ct := range rb.conts {
+		switch ct := ct.(type) {
+		case arrayContainer:
+			for _, x := range ct {
+				buf = le.AppendUint16(buf, x)
+			}
+		case *bitmapContainer:
+			for _, word := range ct.words {
+				buf = le.AppendUint64(buf, word)
+			}
+		case runContainer:
+			buf = le.AppendUint16(buf, uint16(len(ct)))
+			for _, rn := range ct {
+				buf = le.AppendUint16(buf, rn.start)
+				buf = le.AppendUint16(buf, rn.last-rn.start)
+			}
+		}
+	}
+	m, err := w.Write(buf)
+	return int64(m), err
+}
+
+// errRoaringFormat is returned by ReadFrom for malformed input.
+var errRoaringFormat = errors.New("set: malformed Roaring bitmap")
+
+// roaringReader reads little-endian values, keeping the first error.
+type roaringReader struct {
+	r   io.Reader
+	n   int64
+	err error
+	buf [8]byte
+}
+
+func (rr *roaringReader) read(n int) []byte {
+	if rr.err != nil {
+		return rr.buf[:n]
+	}
+	m, err := io.ReadFull(rr.r, rr.buf[:n])
+	rr.n += int64(m)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	rr.err = err
+	return rr.buf[:n]
+}
+
+func (rr *roaringReader) uint16() uint16 { return binary.LittleEndian.Uint16(rr.read(2)) }
+
+func (rr *roaringReader) uint32() uint32 { return binary.LittleEndian.Uint32(rr.read(4)) }
+
+func (rr *roaringReader) uint64() uint64 { return binary.LittleEndian.Uint64(rr.read(8)) }
+
+// ReadFrom replaces the contents of the set with a bitmap read from r in the
+// portable Roaring serialization format, returning the number of bytes read.
+// On error the set is left unchanged.
+func (rb *RoaringBitmap) ReadFrom(r io.Reader) (int64, error) {
+	rr := &roaringReader{r: r}
+	cookie := rr.uint32()
+	var n int
+	var runs []byte
+	switch {
+	case cookie == roaringCookieNoRuns:
+		n = int(rr.uint32())
+	case cookie&0xffff == roaringCookie:
+		n = int(cookie>>16) + 1
+		runs = make([]byte, (n+7)/8)
+		for i := range runs {
+			runs[i] = rr.read(1)[0]
+		}
+	default:
+		if rr.err == nil {
+			return rr.n, errRoaringFormat
+		}
+	}
+	if rr.err != nil {
+		return rr.n, rr.err
+	}
+	if n > 1<<16 {
+		return rr.n, errRoaringFormat
+	}
+	keys := make([]uint16, n)
+	cards := make([]int, n)
+	for i := range keys {
+		keys[i] = rr.uint16()
+		cards[i] = int(rr.uint16()) + 1
+		if i > 0 && keys[i] <= keys[i-1] {
+			return rr.n, errRoaringFormat
+		}
+	}
+	if runs == nil || n >= roaringNoOffsetThresh {
+		// The containers follow one another, so the offsets are not needed.
+		for range n {
+			rr.uint32()
+		}
+	}
+	conts := make([]container, n)
+	for i := range conts {
+		if rr.err != nil {
+			return rr.n, rr.err
+		}
+		switch {
+		case runs != nil && runs[i/8]&(1<<(i%8)) != 0:
+			rc := make(runContainer, rr.uint16())
+			for j := range rc {
+				start := rr.uint16()
+				length := rr.uint16()
+				if int(start)+int(length) > 0xffff || j > 0 && int(start) <= int(rc[j-1].last)+1 {
+					return rr.n, errRoaringFormat
+				}
+				rc[j] = run16{start, start + length}
+			}
+			conts[i] = rc
+		case cards[i] <= arrayMax:
+			ac := make(arrayContainer, cards[i])
+			for j := range ac {
+				ac[j] = rr.uint16()
+				if j > 0 && ac[j] <= ac[j-1] {
+					return rr.n, errRoaringFormat
+				}
+			}
+			conts[i] = ac
+		default:
+			bc := &bitmapContainer{}
+			for j := range bc.words {
+				bc.words[j] = rr.uint64()
+			}
+			bc.recount()
+			conts[i] = bc
+		}
+		if rr.err == nil && conts[i].card() != cards[i] {
+			return rr.n, errRoaringFormat
+		}
+	}
+	if rr.err != nil {
+		return rr.n, rr.err
+	}
+	rb.keys, rb.conts = keys, conts
+	return rr.n, nil
+}
@@ -1,10 +1,16 @@
 package set
 
+import "iter"
+import "slices"
 import c "github.com/dtromb/collections"
 import tree "github.com/dtromb/collections/tree"
 
 // Set is a comparable immutable value comprised of other underlying
-// comparable values.
+// comparable values.  An ordered set iterates its elements in ascending
+// order, and an unordered one (such as a hash set) in an unspecified order.
+// Sets of any kinds may be combined: the result of an operation generally
+// has the kind of its receiver, and set comparison (see CompareTo) does not
+// depend on the kinds of the sets compared.
 type Set interface {
 	c.Comparable
 	Size() int
@@ -12,18 +18,50 @@ type Set interface {
 	Union(s Set) Set
 	Intersection(s Set) Set
 	Difference(s Set) Set
+	SymmetricDifference(s Set) Set
 	OpenCursor() c.Cursor
+	All() iter.Seq[c.Comparable]
 	Ordered() bool
 }
 
+// MutableSet is a Set which can be modified in place.  Each modifying
+// operation returns the number of elements added or removed; elements already
+// present (or absent) are not counted.  Add and Remove take individual
+// elements, and Retain removes every element not among its arguments.  The
+// All variants take their argument elements from another Set.
 type MutableSet interface {
 	Set
-	Add(cs ...c.Comparable)
-	Remove(cs ...c.Comparable)
-	Retain(cs ...c.Comparable)
+	Add(cs ...c.Comparable) int
+	Remove(cs ...c.Comparable) int
+	Retain(cs ...c.Comparable) int
+	AddAll(s Set) int
+	RemoveAll(s Set) int
+	RetainAll(s Set) int
 	Clear()
 }
 
+// RankedSet is implemented by ordered sets that support positional access.
+// Select returns the element at (zero-based) position i, Rank the number of
+// elements less than x (and whether x is present), and CountRange the number
+// of elements in the closed range [lo, hi].
+type RankedSet interface {
+	Set
+	Select(i int) c.Comparable
+	Rank(x c.Comparable) (int, bool)
+	CountRange(lo, hi c.Comparable) int
+}
+
+// RangedSet is implemented by ordered sets that can present the elements in
+// a bounded range as a live view.  Changes to the set are visible through the
+// view and changes made through the view are made to the set; adding an
+// element outside the range of a view panics.
+type RangedSet interface {
+	MutableSet
+	SubSet(lo, hi c.Comparable, loInclusive, hiInclusive bool) RangedSet
+	HeadSet(hi c.Comparable, inclusive bool) RangedSet
+	TailSet(lo c.Comparable, inclusive bool) RangedSet
+}
+
 type emptySet struct{}
 
 func (es *emptySet) CompareTo(c c.Comparable) int8 {
@@ -47,10 +85,16 @@ func (es *emptySet) Intersection(s Set) Set { return es }
 
 func (es *emptySet) Difference(s Set) Set { return es }
 
+func (es *emptySet) SymmetricDifference(s Set) Set { return s }
+
 func (es *emptySet) OpenCursor() c.Cursor {
 	return es
 }
 
+func (es *emptySet) All() iter.Seq[c.Comparable] {
+	return func(yield func(c.Comparable) bool) {}
+}
+
 func (es *emptySet) HasNext() bool {
 	return false
 }
@@ -103,6 +147,13 @@ func (ss *singletonSet) Difference(s Set) Set {
 	}
 }
 
+func (ss *singletonSet) SymmetricDifference(s Set) Set {
+	if s.Contains(ss.x) {
+		return s.Difference(ss)
+	}
+	return s.Union(ss)
+}
+
 func (ss *singletonSet) CompareTo(c c.Comparable) int8 {
 	if s, isSet := c.(Set); isSet {
 		r := s.Size()
@@ -112,6 +163,9 @@ func (ss *singletonSet) CompareTo(c c.Comparable) int8 {
 		if r > 1 {
 			return -1
 		}
+		if !s.Ordered() {
+			return compareSets(ss, s)
+		}
 		return ss.x.CompareTo(s.OpenCursor().Next())
 	}
 	return 1
@@ -126,18 +180,26 @@ func (ss *singletonSet) OpenCursor() c.Cursor {
 	return &ssCursor{set: ss}
 }
 
+func (ss *singletonSet) All() iter.Seq[c.Comparable] {
+	return func(yield func(c.Comparable) bool) {
+		yield(ss.x)
+	}
+}
+
 func (c *ssCursor) HasNext() bool { return !c.read }
 func (c *ssCursor) HasPrev() bool { return c.read }
 func (c *ssCursor) Next() c.Comparable {
 	if c.read {
 		return nil
 	}
+	c.read = true
 	return c.set.x
 }
 func (c *ssCursor) Prev() c.Comparable {
 	if !c.read {
 		return nil
 	}
+	c.read = false
 	return c.set.x
 }
 
@@ -169,7 +231,7 @@ func (ps *pairSet) Union(s Set) Set {
 	if t.Size() == 2 {
 		return ps
 	}
-	return &treeSet{tree: t}
+	return TreeSet(t)
 }
 
 func (ps *pairSet) Intersection(s Set) Set {
@@ -198,6 +260,10 @@ func (ps *pairSet) Difference(s Set) Set {
 	return ps
 }
 
+func (ps *pairSet) SymmetricDifference(s Set) Set {
+	return symmetricDifference(ps, s)
+}
+
 func (ps *pairSet) CompareTo(c c.Comparable) int8 {
 	if s, isSet := c.(Set); isSet {
 		r := s.Size()
@@ -207,6 +273,9 @@ func (ps *pairSet) CompareTo(c c.Comparable) int8 {
 		if r > 2 {
 			return -1
 		}
+		if !s.Ordered() {
+			return compareSets(ps, s)
+		}
 		cc := s.OpenCursor()
 		rp := ps.x.CompareTo(cc.Next())
 		if rp != 0 {
@@ -226,6 +295,12 @@ func (ps *pairSet) OpenCursor() c.Cursor {
 	return &psCursor{ps: ps}
 }
 
+func (ps *pairSet) All() iter.Seq[c.Comparable] {
+	return func(yield func(c.Comparable) bool) {
+		_ = yield(ps.x) && yield(ps.y)
+	}
+}
+
 func (c *psCursor) HasNext() bool {
 	return c.pos < 2
 }
@@ -271,108 +346,310 @@ func Pair(c1, c2 c.Comparable) Set {
 }
 
 type treeSet struct {
-	tree tree.Tree
+	tree  tree.Tree
+	codec c.Codec[c.Comparable]
 }
 
+// rangedTreeSet is a treeSet whose tree supports range views.
+type rangedTreeSet struct {
+	treeSet
+}
+
+// TreeSet returns a MutableSet backed by tree, which also implements
+// RangedSet if tree implements tree.RangedTree.
 func TreeSet(tree tree.Tree) MutableSet {
-	return &treeSet{tree: tree}
+	return newTreeSet(treeSet{tree: tree})
+}
+
+// newTreeSet wraps ts as a rangedTreeSet if its tree supports range views.
+func newTreeSet(ts treeSet) MutableSet {
+	if _, ok := ts.tree.(tree.RangedTree); ok {
+		return &rangedTreeSet{ts}
+	}
+	return &ts
+}
+
+// treeSetOf returns the treeSet underlying s, if it has one.
+func treeSetOf(s Set) (*treeSet, bool) {
+	switch ts := s.(type) {
+	case *treeSet:
+		return ts, true
+	case *rangedTreeSet:
+		return &ts.treeSet, true
+	}
+	return nil, false
 }
 
 func (ts *treeSet) Ordered() bool { return true }
 
 func (ts *treeSet) CompareTo(c c.Comparable) int8 {
+	return compareSets(ts, c)
+}
+
+// compareSets compares a set to another value: smaller sets order first, and
+// sets of equal size are ordered lexicographically by their elements in
+// ascending order, whatever their kinds.  The elements of an unordered set
+// are sorted for the comparison, except that sets found equal by membership
+// are reported equal without sorting either.
+func compareSets(ts Set, c c.Comparable) int8 {
 	if os, isSet := c.(Set); isSet {
-		oz := uint(os.Size())
-		tz := ts.tree.Size()
+		oz := os.Size()
+		tz := ts.Size()
 		if tz < oz {
 			return -1
 		}
 		if tz > oz {
 			return 1
 		}
-		if os.Ordered() {
-			cc := ts.OpenCursor()
-			oc := os.OpenCursor()
-			for cc.HasNext() {
-				r := cc.Next().CompareTo(oc.Next())
-				if r != 0 {
-					return r
-				}
-			}
+		if (!ts.Ordered() || !os.Ordered()) && sameMembers(ts, os) {
 			return 0
 		}
-		panic("cannot compare non-ordered sets")
+		cc := sortedCursor(ts)
+		oc := sortedCursor(os)
+		for cc.HasNext() {
+			r := cc.Next().CompareTo(oc.Next())
+			if r != 0 {
+				return r
+			}
+		}
+		return 0
 	}
 	return 1
 }
 
+// sameMembers checks whether every element of a is in b, which for sets of
+// equal size makes them equal.
+func sameMembers(a, b Set) bool {
+	for x := range a.All() {
+		if !b.Contains(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedCursor opens a cursor over the elements of s in ascending order.
+func sortedCursor(s Set) c.Cursor {
+	if s.Ordered() {
+		return s.OpenCursor()
+	}
+	vals := slices.Collect(s.All())
+	slices.SortFunc(vals, func(a, b c.Comparable) int { return int(a.CompareTo(b)) })
+	return c.FromSeq(slices.Values(vals))
+}
+
 func (ts *treeSet) Size() int { return int(ts.tree.Size()) }
 
 func (ts *treeSet) Contains(cm c.Comparable) bool {
-	_, has := ts.tree.Lookup(c.LTE, cm)
+	_, has := ts.tree.Lookup(c.EQ, cm)
 	return has
 }
 
+// derive returns a set of the elements of t which encodes them as the
+// receiver does.
+func (ts *treeSet) derive(t tree.Tree) Set {
+	return TreeSetWithCodec(t, ts.elementCodec())
+}
+
 func (ts *treeSet) Union(s Set) Set {
-	nt := tree.NewTree()
-	tsc := ts.OpenCursor()
-	for tsc.HasNext() {
-		nt.Insert(tsc.Next())
+	if s.Ordered() {
+		return ts.derive(tree.BuildFromSortedSlice(mergeOrdered(ts, s, keepLeft|keepRight|keepBoth)))
 	}
+	nt := tree.BuildFromSorted(ts.OpenCursor())
 	sc := s.OpenCursor()
 	for sc.HasNext() {
 		nt.Insert(sc.Next())
 	}
-	return TreeSet(nt)
+	return ts.derive(nt)
 }
 
 func (ts *treeSet) Intersection(s Set) Set {
 	if ts.Size() > s.Size() {
 		return s.Intersection(ts)
 	}
-	nt := tree.NewTree()
-	c := ts.OpenCursor()
-	for c.HasNext() {
-		k := c.Next()
+	if s.Ordered() && preferMerge(ts.Size(), s.Size()) {
+		return ts.derive(tree.BuildFromSortedSlice(mergeOrdered(ts, s, keepBoth)))
+	}
+	var vals []c.Comparable
+	cc := ts.OpenCursor()
+	for cc.HasNext() {
+		k := cc.Next()
 		if s.Contains(k) {
-			nt.Insert(k)
+			vals = append(vals, k)
 		}
 	}
-	return TreeSet(nt)
+	return ts.derive(tree.BuildFromSortedSlice(vals))
 }
 
 func (ts *treeSet) Difference(s Set) Set {
-	nt := tree.NewTree()
-	c := ts.OpenCursor()
-	for c.HasNext() {
-		k := c.Next()
+	if s.Ordered() && preferMerge(ts.Size(), s.Size()) {
+		return ts.derive(tree.BuildFromSortedSlice(mergeOrdered(ts, s, keepLeft)))
+	}
+	var vals []c.Comparable
+	cc := ts.OpenCursor()
+	for cc.HasNext() {
+		k := cc.Next()
 		if !s.Contains(k) {
-			nt.Insert(k)
+			vals = append(vals, k)
 		}
 	}
-	return TreeSet(nt)
+	return ts.derive(tree.BuildFromSortedSlice(vals))
+}
+
+func (ts *treeSet) SymmetricDifference(s Set) Set {
+	if s.Ordered() {
+		return ts.derive(tree.BuildFromSortedSlice(mergeOrdered(ts, s, keepLeft|keepRight)))
+	}
+	return symmetricDifference(ts, s)
 }
 
 func (ts *treeSet) OpenCursor() c.Cursor {
 	return ts.tree.First()
 }
 
-func (ts *treeSet) Add(cs ...c.Comparable) {
+func (ts *treeSet) All() iter.Seq[c.Comparable] {
+	if at, ok := ts.tree.(*tree.AvlTree); ok {
+		return at.All()
+	}
+	return c.Values(ts.tree.First())
+}
+
+func (ts *treeSet) Select(i int) c.Comparable {
+	if i < 0 || i >= ts.Size() {
+		return nil
+	}
+	if rt, ok := ts.tree.(tree.RankedTree); ok {
+		return rt.Select(uint(i))
+	}
+	cc := ts.OpenCursor()
+	for ; i > 0; i-- {
+		cc.Next()
+	}
+	return cc.Next()
+}
+
+func (ts *treeSet) Rank(x c.Comparable) (int, bool) {
+	if rt, ok := ts.tree.(tree.RankedTree); ok {
+		r, has := rt.Rank(x)
+		return int(r), has
+	}
+	var r int
+	cc := ts.OpenCursor()
+	for cc.HasNext() {
+		o := x.CompareTo(cc.Next())
+		if o <= 0 {
+			return r, o == 0
+		}
+		r++
+	}
+	return r, false
+}
+
+func (ts *treeSet) CountRange(lo, hi c.Comparable) int {
+	if rt, ok := ts.tree.(tree.RankedTree); ok {
+		return int(rt.CountRange(lo, hi))
+	}
+	if lo.CompareTo(hi) > 0 {
+		return 0
+	}
+	var n int
+	cc, _ := ts.tree.GetCursor(c.GTE, lo)
+	for cc.HasNext() && cc.Next().CompareTo(hi) <= 0 {
+		n++
+	}
+	return n
+}
+
+func (ts *treeSet) Add(cs ...c.Comparable) int {
+	var n int
 	for _, k := range cs {
-		ts.tree.Insert(k)
+		if ts.tree.Insert(k) == nil {
+			n++
+		}
 	}
+	return n
 }
 
-func (ts *treeSet) Remove(cs ...c.Comparable) {
+func (ts *treeSet) Remove(cs ...c.Comparable) int {
+	var n int
 	for _, k := range cs {
-		ts.tree.Delete(k)
+		if _, found := ts.tree.Delete(k); found {
+			n++
+		}
 	}
+	return n
 }
 
-func (ts *treeSet) Retain(cs ...c.Comparable) {
-	panic("unimplemented")
+func (ts *treeSet) Retain(cs ...c.Comparable) int {
+	keep := TreeSet(tree.NewTree())
+	keep.Add(cs...)
+	return ts.RetainAll(keep)
 }
 
+// The bulk operations below find the elements to add or remove with an
+// ordered merge when that is cheaper than a lookup per element, and then
+// update the tree element by element, so that they apply equally to views.
+
+func (ts *treeSet) AddAll(s Set) int {
+	if s.Ordered() && preferMerge(s.Size(), ts.Size()) {
+		return ts.Add(mergeOrdered(ts, s, keepRight)...)
+	}
+	var n int
+	sc := s.OpenCursor()
+	for sc.HasNext() {
+		n += ts.Add(sc.Next())
+	}
+	return n
+}
+
+func (ts *treeSet) RemoveAll(s Set) int {
+	if s.Ordered() && preferMerge(s.Size(), ts.Size()) {
+		return ts.Remove(mergeOrdered(ts, s, keepBoth)...)
+	}
+	var n int
+	sc := s.OpenCursor()
+	for sc.HasNext() {
+		n += ts.Remove(sc.Next())
+	}
+	return n
+}
+
+func (ts *treeSet) RetainAll(s Set) int {
+	if s.Ordered() && preferMerge(ts.Size(), s.Size()) {
+		return ts.Remove(mergeOrdered(ts, s, keepLeft)...)
+	}
+	var doomed []c.Comparable
+	cc := ts.OpenCursor()
+	for cc.HasNext() {
+		if k := cc.Next(); !s.Contains(k) {
+			doomed = append(doomed, k)
+		}
+	}
+	return ts.Remove(doomed...)
+}
+
+// Clear removes every element in place, so that views of the set and the
+// tree passed to TreeSet see the change.
 func (ts *treeSet) Clear() {
-	ts.tree = tree.NewTree()
+	if at, ok := ts.tree.(*tree.AvlTree); ok {
+		at.Clear()
+		return
+	}
+	ts.Remove(slices.Collect(ts.All())...)
+}
+
+func (rs *rangedTreeSet) rangedTree() tree.RangedTree {
+	return rs.tree.(tree.RangedTree)
+}
+
+func (rs *rangedTreeSet) SubSet(lo, hi c.Comparable, loInclusive, hiInclusive bool) RangedSet {
+	return &rangedTreeSet{treeSet{tree: rs.rangedTree().SubTree(lo, hi, loInclusive, hiInclusive)}}
+}
+
+func (rs *rangedTreeSet) HeadSet(hi c.Comparable, inclusive bool) RangedSet {
+	return &rangedTreeSet{treeSet{tree: rs.rangedTree().HeadTree(hi, inclusive)}}
+}
+
+func (rs *rangedTreeSet) TailSet(lo c.Comparable, inclusive bool) RangedSet {
+	return &rangedTreeSet{treeSet{tree: rs.rangedTree().TailTree(lo, inclusive)}}
 }
@@ -0,0 +1,1068 @@
+package set
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	c "github.com/dtromb/collections"
+	tree "github.com/dtromb/collections/tree"
+)
+
+type testInt int
+
+func (ti testInt) CompareTo(o c.Comparable) int8 {
+	oi := o.(testInt)
+	if ti < oi {
+		return -1
+	}
+	if ti > oi {
+		return 1
+	}
+	return 0
+}
+
+func (ti testInt) Hash() uint64 { return uint64(ti) % 17 }
+
+func (ti testInt) Equal(o c.Hashable) bool { return ti == o.(testInt) }
+
+// unordered hides the ordering of a set, forcing the lookup-based algorithms.
+type unordered struct {
+	Set
+}
+
+func (u unordered) Ordered() bool { return false }
+
+var kinds = []struct {
+	name string
+	make func(xs []int) Set
+}{
+	{"AVL", func(xs []int) Set { return fill(TreeSet(tree.NewTree(tree.AVL_THREAD)), xs) }},
+	{"RB", func(xs []int) Set { return fill(TreeSet(tree.NewTree(tree.RB_THREAD)), xs) }},
+	{"BTREE", func(xs []int) Set { return fill(TreeSet(tree.NewTree(tree.BTREE)), xs) }},
+	{"PERSISTENT", func(xs []int) Set {
+		ps := Persistent()
+		for _, x := range xs {
+			ps = ps.With(testInt(x))
+		}
+		return ps
+	}},
+	{"HASH", func(xs []int) Set { return fill(HashSet(), xs) }},
+	{"UNORDERED", func(xs []int) Set { return unordered{fill(TreeSet(tree.NewTree(tree.RB_THREAD)), xs)} }},
+	{"SMALL", func(xs []int) Set {
+		switch len(xs) {
+		case 0:
+			return Empty()
+		case 1:
+			return Singleton(testInt(xs[0]))
+		case 2:
+			return Pair(testInt(xs[0]), testInt(xs[1]))
+		}
+		return fill(TreeSet(tree.NewTree()), xs)
+	}},
+}
+
+func fill(s MutableSet, xs []int) Set {
+	for _, x := range xs {
+		s.Add(testInt(x))
+	}
+	return s
+}
+
+// toInt returns the value of a test element, which is a testInt or an Int.
+func toInt(x c.Comparable) int {
+	if i, ok := x.(Int); ok {
+		return int(i)
+	}
+	return int(x.(testInt))
+}
+
+// contents returns the elements of s in ascending order, checking that an
+// ordered set iterates them that way.
+func contents(s Set) []int {
+	var r []int
+	cc := s.OpenCursor()
+	for cc.HasNext() {
+		r = append(r, toInt(cc.Next()))
+	}
+	if s.Ordered() && !sort.IntsAreSorted(r) {
+		panic("ordered set iterated out of order")
+	}
+	sort.Ints(r)
+	return r
+}
+
+// model is the expected contents of a set or multiset under test, holding
+// the number of times each element is present.
+type model map[c.Comparable]int
+
+// modelOf returns the model of the elements of s.
+func modelOf(s Set) model {
+	m := make(model)
+	for x := range s.All() {
+		m[x] = 1
+	}
+	return m
+}
+
+// elements lists the elements of the model in ascending order, each repeated
+// by its count.
+func (m model) elements() []int {
+	var r []int
+	for x, n := range m {
+		for i := 0; i < n; i++ {
+			r = append(r, toInt(x))
+		}
+	}
+	sort.Ints(r)
+	return r
+}
+
+// checkModel fails the test unless s holds exactly the elements of m,
+// iterating them in ascending order (if s is ordered) in each direction.
+func checkModel(t *testing.T, op string, s interface {
+	Size() int
+	Contains(x c.Comparable) bool
+	OpenCursor() c.Cursor
+}, ordered bool, m model) {
+	t.Helper()
+	want := m.elements()
+	var got, back []int
+	cc := s.OpenCursor()
+	for cc.HasNext() {
+		got = append(got, toInt(cc.Next()))
+	}
+	for cc.HasPrev() {
+		back = append(back, toInt(cc.Prev()))
+	}
+	slices.Reverse(back)
+	if !ordered {
+		sort.Ints(got)
+		sort.Ints(back)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) || fmt.Sprint(back) != fmt.Sprint(want) || s.Size() != len(want) {
+		t.Fatalf("%s: cursor returned %v then %v, expected %v", op, got, back, want)
+	}
+	for x, n := range m {
+		if s.Contains(x) != (n > 0) {
+			t.Fatalf("%s: Contains(%v) = %v", op, x, s.Contains(x))
+		}
+	}
+}
+
+// checkMutations applies rounds of bulk and element-wise modifications to ms,
+// following each in a model and checking the count returned and the contents
+// that result.  Each round takes its operand from operand, both as a set and
+// as the elements of the set, and extra, if set, then makes the checks
+// particular to the kind of ms.
+func checkMutations(t *testing.T, ms MutableSet, rounds int, operand func(round int) (Set, []c.Comparable), extra func(op string, m model)) {
+	t.Helper()
+	m := make(model)
+	for round := 0; round < rounds; round++ {
+		s, cs := operand(round)
+		in := make(map[c.Comparable]bool)
+		for _, x := range cs {
+			in[x] = true
+		}
+		var op string
+		var n, changed int
+		switch round % 8 {
+		case 0, 4:
+			op, n = "AddAll", ms.AddAll(s)
+		case 1:
+			op, n = "RemoveAll", ms.RemoveAll(s)
+		case 2, 6:
+			op, n = "Add", ms.Add(cs...)
+		case 3:
+			op, n = "RetainAll", ms.RetainAll(s)
+		case 5:
+			op, n = "Remove", ms.Remove(cs...)
+		case 7:
+			op, n = "Retain", ms.Retain(cs...)
+		}
+		switch op {
+		case "AddAll", "Add":
+			for x := range in {
+				if m[x] == 0 {
+					m[x] = 1
+					changed++
+				}
+			}
+		case "RemoveAll", "Remove":
+			for x := range in {
+				if m[x] > 0 {
+					delete(m, x)
+					changed++
+				}
+			}
+		default:
+			for x := range m {
+				if !in[x] {
+					delete(m, x)
+					changed++
+				}
+			}
+		}
+		if n != changed {
+			t.Fatalf("%s changed %d elements, expected %d", op, n, changed)
+		}
+		checkModel(t, op, ms, ms.Ordered(), m)
+		if extra != nil {
+			extra(op, m)
+		}
+	}
+}
+
+func randomInts(rnd *rand.Rand, n, max int) []int {
+	var r []int
+	for i := 0; i < n; i++ {
+		r = append(r, rnd.Intn(max))
+	}
+	return r
+}
+
+func TestSetAlgebra(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, sizes := range [][2]int{{0, 0}, {0, 5}, {1, 2}, {2, 2}, {2, 40}, {50, 60}, {300, 3}, {10, 2000}} {
+		xs, ys := randomInts(rnd, sizes[0], 100), randomInts(rnd, sizes[1], 100)
+		inX, inY := make(map[int]bool), make(map[int]bool)
+		for _, x := range xs {
+			inX[x] = true
+		}
+		for _, y := range ys {
+			inY[y] = true
+		}
+		expect := func(keep func(x, y bool) bool) string {
+			var r []int
+			for i := 0; i < 100; i++ {
+				if keep(inX[i], inY[i]) {
+					r = append(r, i)
+				}
+			}
+			return fmt.Sprint(r)
+		}
+		union := expect(func(x, y bool) bool { return x || y })
+		inter := expect(func(x, y bool) bool { return x && y })
+		diff := expect(func(x, y bool) bool { return x && !y })
+		sym := expect(func(x, y bool) bool { return x != y })
+		for _, ka := range kinds {
+			for _, kb := range kinds {
+				if (ka.name == "SMALL" && len(inX) > 2) || (kb.name == "SMALL" && len(inY) > 2) {
+					continue
+				}
+				a, b := ka.make(xs), kb.make(ys)
+				name := fmt.Sprintf("%s(%d) %s(%d)", ka.name, len(inX), kb.name, len(inY))
+				for _, op := range []struct {
+					name   string
+					result Set
+					expect string
+				}{
+					{"Union", a.Union(b), union},
+					{"Intersection", a.Intersection(b), inter},
+					{"Difference", a.Difference(b), diff},
+					{"SymmetricDifference", a.SymmetricDifference(b), sym},
+				} {
+					if got := fmt.Sprint(contents(op.result)); got != op.expect {
+						t.Errorf("%s: %s = %s, expected %s", name, op.name, got, op.expect)
+					}
+				}
+				if got := fmt.Sprint(contents(a)); got != expect(func(x, y bool) bool { return x }) {
+					t.Errorf("%s: operations modified the receiver", name)
+				}
+			}
+		}
+	}
+}
+
+// benchmarkOp times op between sets of n and m elements drawn from the same
+// range, built with each of the given kinds.
+func benchmarkOp(b *testing.B, n, m int, op func(a, b Set) Set) {
+	rnd := rand.New(rand.NewSource(1))
+	xs, ys := randomInts(rnd, n, 2*(n+m)), randomInts(rnd, m, 2*(n+m))
+	for _, kind := range []struct {
+		name     string
+		make     func(xs []int) Set
+		argument func(s Set) Set
+	}{
+		{"join", kinds[0].make, func(s Set) Set { return s }},
+		{"merge", kinds[1].make, func(s Set) Set { return s }},
+		{"lookup", kinds[1].make, func(s Set) Set { return unordered{s} }},
+	} {
+		sa, sb := kind.make(xs), kind.argument(kind.make(ys))
+		b.Run(fmt.Sprintf("%s/%d-%d", kind.name, n, m), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				op(sa, sb)
+			}
+		})
+	}
+}
+
+func BenchmarkUnion(b *testing.B) {
+	benchmarkOp(b, 10000, 10000, Set.Union)
+	benchmarkOp(b, 10000, 100, Set.Union)
+}
+
+func BenchmarkIntersection(b *testing.B) {
+	benchmarkOp(b, 10000, 10000, Set.Intersection)
+	benchmarkOp(b, 10000, 100, Set.Intersection)
+}
+
+func BenchmarkDifference(b *testing.B) {
+	benchmarkOp(b, 10000, 10000, Set.Difference)
+	benchmarkOp(b, 10000, 100, Set.Difference)
+}
+
+func BenchmarkSymmetricDifference(b *testing.B) {
+	benchmarkOp(b, 10000, 10000, Set.SymmetricDifference)
+	benchmarkOp(b, 10000, 100, Set.SymmetricDifference)
+}
+
+func TestCompareMixed(t *testing.T) {
+	xs := []int{5, 3, 9, 1}
+	var sets []Set
+	for _, k := range kinds {
+		sets = append(sets, k.make(xs))
+	}
+	sets = append(sets, HashSet(testInt(1), testInt(3), testInt(9), testInt(6)))
+	for _, a := range sets[:len(sets)-1] {
+		for _, b := range sets[:len(sets)-1] {
+			if r := a.CompareTo(b); r != 0 {
+				t.Errorf("equal sets %v and %v compare %d", contents(a), contents(b), r)
+			}
+		}
+		last := sets[len(sets)-1]
+		if a.CompareTo(last) != -1 || last.CompareTo(a) != 1 {
+			t.Errorf("%v does not order before %v", contents(a), contents(last))
+		}
+		if a.CompareTo(HashSet(testInt(1))) != 1 || HashSet().CompareTo(a) != -1 {
+			t.Errorf("%v does not order by size", contents(a))
+		}
+	}
+	if Pair(testInt(1), testInt(4)).CompareTo(HashSet(testInt(4), testInt(18))) != -1 {
+		t.Error("pair does not compare to hash set by sorted elements")
+	}
+
+	// Equal hash sets are found without ordering their elements.
+	a := HashSet(unorderable(1), unorderable(2), unorderable(3))
+	b := HashSet(unorderable(3), unorderable(1), unorderable(2))
+	if a.CompareTo(b) != 0 {
+		t.Error("equal hash sets of unorderable elements do not compare by membership")
+	}
+	if Singleton(unorderable(1)).CompareTo(HashSet(unorderable(1))) != 0 {
+		t.Error("singleton does not compare to hash set by membership")
+	}
+}
+
+// TestCompareTransitive checks that sets of mixed kinds are totally ordered,
+// so that they can be held in an ordered collection together.
+func TestCompareTransitive(t *testing.T) {
+	rnd := rand.New(rand.NewSource(8))
+	var sets []Set
+	for i := 0; i < 60; i++ {
+		xs := randomInts(rnd, 1+rnd.Intn(3), 20)
+		sets = append(sets, kinds[rnd.Intn(len(kinds))].make(xs))
+	}
+	sets = append(sets, fill(TreeSet(tree.NewTree()), []int{1, 16}),
+		fill(TreeSet(tree.NewTree()), []int{2, 3}), fill(HashSet(), []int{2, 3}))
+	for _, a := range sets {
+		for _, b := range sets {
+			ab := a.CompareTo(b)
+			if ab != -b.CompareTo(a) {
+				t.Fatalf("%v and %v do not compare antisymmetrically", contents(a), contents(b))
+			}
+			for _, d := range sets {
+				if ab <= 0 && b.CompareTo(d) <= 0 && a.CompareTo(d) > 0 {
+					t.Fatalf("%v <= %v <= %v but %v > %v", contents(a), contents(b), contents(d), contents(a), contents(d))
+				}
+			}
+		}
+	}
+	ts := TreeSet(tree.NewTree())
+	for _, s := range sets {
+		ts.Add(s)
+	}
+	for _, s := range sets {
+		if !ts.Contains(s) {
+			t.Fatalf("tree set of sets does not contain %v", contents(s))
+		}
+	}
+}
+
+// unorderable is a Hashable element whose CompareTo must not be called.
+type unorderable int
+
+func (u unorderable) CompareTo(c.Comparable) int8 { panic("unorderable values compared") }
+
+func (u unorderable) Hash() uint64 { return uint64(u) }
+
+func (u unorderable) Equal(o c.Hashable) bool { return u == o.(unorderable) }
+
+func TestMutableSet(t *testing.T) {
+	rnd := rand.New(rand.NewSource(2))
+	for _, impl := range []string{"AVL", "RB", "BTREE", "HASH"} {
+		for _, arg := range kinds {
+			var ms MutableSet
+			switch impl {
+			case "AVL":
+				ms = TreeSet(tree.NewTree(tree.AVL_THREAD))
+			case "RB":
+				ms = TreeSet(tree.NewTree(tree.RB_THREAD))
+			case "BTREE":
+				ms = TreeSet(tree.NewTree(tree.BTREE))
+			case "HASH":
+				ms = HashSet()
+			}
+			t.Run(impl+"/"+arg.name, func(t *testing.T) {
+				checkMutations(t, ms, 24, func(int) (Set, []c.Comparable) {
+					xs := randomInts(rnd, rnd.Intn(60), 200)
+					if arg.name == "SMALL" {
+						xs = xs[:len(xs)%3]
+					}
+					var cs []c.Comparable
+					for _, x := range xs {
+						cs = append(cs, testInt(x))
+					}
+					return arg.make(xs), cs
+				}, nil)
+			})
+		}
+	}
+}
+
+func TestSetOf(t *testing.T) {
+	s := NewOrderedSet("pear", "apple", "fig")
+	if n := s.Add("fig", "kiwi"); n != 1 {
+		t.Fatalf("Add counted %d new elements, expected 1", n)
+	}
+	if n := s.Remove("plum", "apple"); n != 1 {
+		t.Fatalf("Remove counted %d elements, expected 1", n)
+	}
+	if n := s.RetainAll(NewOrderedSet("fig", "kiwi", "lime")); n != 1 {
+		t.Fatalf("RetainAll removed %d elements, expected 1", n)
+	}
+	if got := fmt.Sprint(slices.Collect(s.All())); got != "[fig kiwi]" {
+		t.Fatalf("set holds %v, expected [fig kiwi]", got)
+	}
+	for _, o := range []struct {
+		s    SetOf[string]
+		want int8
+	}{
+		{NewOrderedSet("kiwi", "fig"), 0},
+		{NewOrderedSet("fig", "lime"), -1},
+		{NewOrderedSet("date", "kiwi"), 1},
+		{NewOrderedSet("a", "b", "c"), -1},
+		{NewOrderedSet("zebra"), 1},
+	} {
+		if r := s.CompareTo(o.s); r != o.want || o.s.CompareTo(s) != -o.want {
+			t.Errorf("CompareTo(%v) = %d, expected %d", slices.Collect(o.s.All()), r, o.want)
+		}
+	}
+	s.Clear()
+	if s.Size() != 0 || s.AddAll(NewOrderedSet("a", "b")) != 2 {
+		t.Fatal("set not refilled after Clear")
+	}
+}
+
+func TestTreeSetClear(t *testing.T) {
+	for _, impl := range []tree.TreeImplementation{tree.AVL_THREAD, tree.RB_THREAD, tree.BTREE} {
+		tr := tree.NewTree(impl)
+		ts := fill(TreeSet(tr), []int{1, 2, 3, 4, 5}).(MutableSet)
+		ts.Clear()
+		if tr.Size() != 0 || ts.Size() != 0 {
+			t.Fatalf("%v: Clear left %d elements in the tree", impl, tr.Size())
+		}
+		ts.Add(testInt(7))
+		if !tr.Has(testInt(7)) {
+			t.Fatalf("%v: set no longer backed by its tree after Clear", impl)
+		}
+	}
+	ts := fill(TreeSet(tree.NewTree()), []int{1, 2, 3, 4, 5}).(MutableSet)
+	head := ts.(RangedSet).HeadSet(testInt(3), false)
+	ts.Clear()
+	ts.Add(testInt(1), testInt(4))
+	if fmt.Sprint(contents(head)) != "[1]" {
+		t.Fatalf("view holds %v after Clear, expected [1]", contents(head))
+	}
+	head.Clear()
+	if fmt.Sprint(contents(ts)) != "[4]" {
+		t.Fatalf("set holds %v after clearing a view, expected [4]", contents(ts))
+	}
+}
+
+func TestTreeSetRanged(t *testing.T) {
+	for impl, ranged := range map[tree.TreeImplementation]bool{
+		tree.AVL_THREAD: true, tree.RB_THREAD: false, tree.BTREE: false, tree.SKIPLIST: false,
+	} {
+		if _, ok := TreeSet(tree.NewTree(impl)).(RangedSet); ok != ranged {
+			t.Errorf("%v: TreeSet implements RangedSet is %v, expected %v", impl, ok, ranged)
+		}
+	}
+	ts := fill(TreeSet(tree.NewTree()), []int{1, 2, 3, 4, 5}).(RangedSet)
+	sub := ts.TailSet(testInt(2), true).HeadSet(testInt(4), false)
+	if fmt.Sprint(contents(sub)) != "[2 3]" {
+		t.Fatalf("view of a view holds %v, expected [2 3]", contents(sub))
+	}
+}
+
+func TestBitSet(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	bs := NewBitSet()
+	checkMutations(t, bs, 40, func(round int) (Set, []c.Comparable) {
+		xs := randomInts(rnd, rnd.Intn(80), 300)
+		var cs []c.Comparable
+		for _, x := range xs {
+			cs = append(cs, Int(x))
+		}
+		// Odd rounds take the operand as a tree set, exercising conversion.
+		if round%2 == 1 {
+			ts := TreeSet(tree.NewTree())
+			ts.Add(cs...)
+			return ts, cs
+		}
+		return NewBitSet(xs...), cs
+	}, func(op string, m model) {
+		next, prev := -1, -1
+		for i := 300; i >= -1; i-- {
+			if m[Int(i)] > 0 {
+				next = i
+			}
+			if bs.NextSetBit(i) != next {
+				t.Fatalf("after %s, NextSetBit(%d) = %d, expected %d", op, i, bs.NextSetBit(i), next)
+			}
+		}
+		for i := -1; i <= 300; i++ {
+			if m[Int(i)] > 0 {
+				prev = i
+			}
+			if bs.PrevSetBit(i) != prev {
+				t.Fatalf("after %s, PrevSetBit(%d) = %d, expected %d", op, i, bs.PrevSetBit(i), prev)
+			}
+		}
+	})
+
+	a, b := randomInts(rnd, 100, 300), randomInts(rnd, 100, 300)
+	ba, bb := NewBitSet(a...), NewBitSet(b...)
+	ta, tb := TreeSet(tree.NewTree()), TreeSet(tree.NewTree())
+	for _, x := range a {
+		ta.Add(Int(x))
+	}
+	for _, x := range b {
+		tb.Add(Int(x))
+	}
+	ops := []struct {
+		name string
+		op   func(a, b Set) Set
+	}{
+		{"Union", Set.Union},
+		{"Intersection", Set.Intersection},
+		{"Difference", Set.Difference},
+		{"SymmetricDifference", Set.SymmetricDifference},
+	}
+	for _, op := range ops {
+		want := op.op(ta, tb)
+		for _, got := range []Set{op.op(ba, bb), op.op(ba, tb)} {
+			if got.CompareTo(want) != 0 || want.CompareTo(got) != 0 {
+				t.Errorf("%s of BitSets differs from that of tree sets", op.name)
+			}
+		}
+	}
+
+	cc := ba.OpenCursor()
+	x := cc.Next()
+	y := cc.Next()
+	if cc.Prev() != y || cc.Prev() != x || cc.HasPrev() {
+		t.Error("BitSet cursor does not return the same element on changing direction")
+	}
+
+	if rt := BitSetFromSelection(ba.ToSelection(300)); fmt.Sprint(contents(rt)) != fmt.Sprint(contents(ba)) {
+		t.Error("Selection round trip changed the set")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("AddAll of a non-Int element did not panic")
+			}
+		}()
+		ts := TreeSet(tree.NewTree())
+		ts.Add(testInt(1))
+		NewBitSet().AddAll(ts)
+	}()
+
+	// A sparse operand with a huge element must not size the result.
+	huge := Pair(Int(1), Int(1<<40))
+	small := NewBitSet(1, 5, 70)
+	if r := small.Intersection(huge).(*BitSet); fmt.Sprint(contents(r)) != "[1]" || len(r.words) != 1 {
+		t.Errorf("Intersection with a sparse set gave %v in %d words", contents(r), len(r.words))
+	}
+	if r := small.Difference(huge).(*BitSet); fmt.Sprint(contents(r)) != "[5 70]" || len(r.words) != 2 {
+		t.Errorf("Difference with a sparse set gave %v in %d words", contents(r), len(r.words))
+	}
+	if n := small.RetainAll(Singleton(Int(1 << 40))); n != 3 || len(small.words) != 0 {
+		t.Errorf("RetainAll of a sparse set removed %d, leaving %d words", n, len(small.words))
+	}
+	small = NewBitSet(1, 5)
+	if n := small.Retain(Int(5), Int(1<<40)); n != 1 || len(small.words) != 1 {
+		t.Errorf("Retain of a huge element removed %d, leaving %d words", n, len(small.words))
+	}
+}
+
+func TestRoaringBitmap(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	// Elements fall in three containers: one sparse, one dense and one
+	// holding long runs, so that every container kind is exercised.
+	random := func() []int {
+		var xs []int
+		for _, x := range randomInts(rnd, rnd.Intn(3000), 1<<16) {
+			xs = append(xs, 3<<16+x/8, 5<<16+x)
+		}
+		for i := 0; i < 3; i++ {
+			start, n := rnd.Intn(1<<16), rnd.Intn(12000)
+			for j := 0; j < n; j++ {
+				xs = append(xs, 7<<16+(start+j)%(1<<16))
+			}
+		}
+		return xs
+	}
+	rb := NewRoaringBitmap()
+	checkMutations(t, rb, 28, func(round int) (Set, []c.Comparable) {
+		xs := random()
+		var cs []c.Comparable
+		for _, x := range xs {
+			cs = append(cs, Int(x))
+		}
+		if round%3 == 2 {
+			rb.RunOptimize()
+		}
+		if round%2 == 1 {
+			return NewBitSet(xs...), cs
+		}
+		return NewRoaringBitmap(xs...), cs
+	}, func(op string, m model) {
+		for i := 0; i < 100; i++ {
+			x := Int(rnd.Intn(8 << 16))
+			if rb.Contains(x) != (m[x] > 0) {
+				t.Fatalf("after %s, Contains(%d) = %v", op, x, rb.Contains(x))
+			}
+		}
+	})
+
+	a, b := random(), random()
+	ra, rb2 := NewRoaringBitmap(a...), NewRoaringBitmap(b...)
+	ba, bb := NewBitSet(a...), NewBitSet(b...)
+	rb2.RunOptimize()
+	for _, op := range []struct {
+		name string
+		op   func(a, b Set) Set
+	}{
+		{"Union", Set.Union},
+		{"Intersection", Set.Intersection},
+		{"Difference", Set.Difference},
+		{"SymmetricDifference", Set.SymmetricDifference},
+	} {
+		want := op.op(ba, bb)
+		for _, got := range []Set{op.op(ra, rb2), op.op(ra, bb)} {
+			if got.CompareTo(want) != 0 {
+				t.Errorf("%s of RoaringBitmaps differs from that of BitSets", op.name)
+			}
+		}
+		if op.op(rb2, ra).CompareTo(op.op(bb, ba)) != 0 {
+			t.Errorf("%s of RoaringBitmaps differs from that of BitSets", op.name)
+		}
+	}
+
+	cc := ra.OpenCursor()
+	x := cc.Next()
+	y := cc.Next()
+	if cc.Prev() != y || cc.Prev() != x || cc.HasPrev() {
+		t.Error("RoaringBitmap cursor does not return the same element on changing direction")
+	}
+
+	for _, opt := range []bool{false, true} {
+		if opt {
+			ra.RunOptimize()
+		}
+		var buf bytes.Buffer
+		n, err := ra.WriteTo(&buf)
+		if err != nil || n != int64(buf.Len()) {
+			t.Fatalf("WriteTo returned %d, %v for %d bytes", n, err, buf.Len())
+		}
+		size := int64(buf.Len())
+		rt := &RoaringBitmap{}
+		if n, err := rt.ReadFrom(&buf); err != nil || n != size {
+			t.Fatalf("ReadFrom returned %d, %v", n, err)
+		}
+		if fmt.Sprint(contents(rt)) != fmt.Sprint(contents(ba)) {
+			t.Error("serialization round trip changed the set")
+		}
+	}
+	checkModel(t, "RunOptimize", ra, true, modelOf(ba))
+
+	// Encodings worked by hand from the format specification.
+	small := NewRoaringBitmap(1, 2, 3)
+	runs := &RoaringBitmap{}
+	for i := 0; i < 100; i++ {
+		runs.Add(Int(i))
+	}
+	runs.RunOptimize()
+	for _, enc := range []struct {
+		rb   *RoaringBitmap
+		want []byte
+	}{
+		{small, []byte{0x3a, 0x30, 0, 0, 1, 0, 0, 0, 0, 0, 2, 0, 16, 0, 0, 0, 1, 0, 2, 0, 3, 0}},
+		{runs, []byte{0x3b, 0x30, 0, 0, 1, 0, 0, 99, 0, 1, 0, 0, 0, 99, 0}},
+	} {
+		var buf bytes.Buffer
+		enc.rb.WriteTo(&buf)
+		if !bytes.Equal(buf.Bytes(), enc.want) {
+			t.Errorf("serialized %v as % x, expected % x", contents(enc.rb), buf.Bytes(), enc.want)
+		}
+		rt := &RoaringBitmap{}
+		if _, err := rt.ReadFrom(bytes.NewReader(enc.want)); err != nil || rt.CompareTo(enc.rb) != 0 {
+			t.Errorf("failed to read % x: %v", enc.want, err)
+		}
+		if _, err := rt.ReadFrom(bytes.NewReader(enc.want[:len(enc.want)-1])); err == nil {
+			t.Errorf("read truncated % x without error", enc.want)
+		}
+	}
+}
+
+func TestMultiset(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	random := func(impl tree.TreeImplementation) (Multiset, model) {
+		ms, m := NewMultiset(impl), make(model)
+		for _, x := range randomInts(rnd, rnd.Intn(40), 50) {
+			n := rnd.Intn(4)
+			m[testInt(x)] += n
+			if k := ms.Add(testInt(x), n); k != m[testInt(x)] {
+				t.Fatalf("Add returned count %d, expected %d", k, m[testInt(x)])
+			}
+		}
+		return ms, m
+	}
+	// check adds to checkModel the views particular to a Multiset.
+	check := func(op string, ms Multiset, m model) {
+		checkModel(t, op, ms, true, m)
+		if got := fmt.Sprint(slices.Collect(ms.All())); got != fmt.Sprint(m.elements()) {
+			t.Fatalf("%s: All returned %v, expected %v", op, got, m.elements())
+		}
+		distinct := make(model)
+		for x := 0; x < 50; x++ {
+			if n := m[testInt(x)]; ms.Count(testInt(x)) != n {
+				t.Fatalf("%s: Count(%d) = %d, expected %d", op, x, ms.Count(testInt(x)), n)
+			} else if n > 0 {
+				distinct[testInt(x)] = 1
+			}
+		}
+		checkModel(t, op+"/Distinct", ms.Distinct(), true, distinct)
+		counts := make(model)
+		for k, n := range ms.Counts() {
+			counts[k] = n
+		}
+		maps.DeleteFunc(m, func(_ c.Comparable, n int) bool { return n == 0 })
+		if !maps.Equal(counts, m) {
+			t.Fatalf("%s: Counts returned %v, expected %v", op, counts, m)
+		}
+	}
+	for _, impl := range []tree.TreeImplementation{tree.AVL_THREAD, tree.RB_THREAD, tree.BTREE, tree.CONCURRENT_SKIPLIST, tree.SKIPLIST, tree.SPLAY, tree.TREAP} {
+		for round := 0; round < 20; round++ {
+			a, am := random(impl)
+			b, bm := random(impl)
+			check("Add", a, am)
+			for _, op := range []struct {
+				name string
+				op   func(a, b Multiset) Multiset
+				want func(x, y int) int
+			}{
+				{"Union", Multiset.Union, func(x, y int) int { return max(x, y) }},
+				{"Sum", Multiset.Sum, func(x, y int) int { return x + y }},
+				{"Intersection", Multiset.Intersection, func(x, y int) int { return min(x, y) }},
+				{"Difference", Multiset.Difference, func(x, y int) int { return max(x-y, 0) }},
+			} {
+				m := make(model)
+				for x := 0; x < 50; x++ {
+					m[testInt(x)] = op.want(am[testInt(x)], bm[testInt(x)])
+				}
+				check(op.name, op.op(a, b), m)
+			}
+			for _, x := range randomInts(rnd, 20, 50) {
+				n := rnd.Intn(4)
+				removed := min(n, am[testInt(x)])
+				am[testInt(x)] -= removed
+				if r := a.Remove(testInt(x), n); r != removed {
+					t.Fatalf("Remove returned %d, expected %d", r, removed)
+				}
+			}
+			check("Remove", a, am)
+			if r := a.CompareTo(a.Sum(NewMultiset())); r != 0 {
+				t.Fatalf("multiset compared %d to a copy of itself", r)
+			}
+			if a.Size() > 0 && a.CompareTo(a.Sum(b)) >= 0 && b.Size() > 0 {
+				t.Fatal("multiset did not order before a larger one")
+			}
+		}
+	}
+}
+
+func TestIntervalSet(t *testing.T) {
+	rnd := rand.New(rand.NewSource(6))
+	// Points are checked over a domain wider than that of the range bounds,
+	// so that unbounded ranges are seen to extend past them.
+	const lo, hi = -5, 105
+	random := func() (*IntervalSet, model) {
+		var rs []Range
+		m := make(model)
+		for i := rnd.Intn(6); i > 0; i-- {
+			a := rnd.Intn(100)
+			r := Range{Int(a), Int(a + rnd.Intn(20))}
+			switch rnd.Intn(8) {
+			case 0:
+				r.Lo = nil
+			case 1:
+				r.Hi = nil
+			}
+			rs = append(rs, r)
+			for p := lo; p < hi; p++ {
+				if r.Contains(Int(p)) {
+					m[Int(p)] = 1
+				}
+			}
+		}
+		return NewIntervalSet(rs...), m
+	}
+	// check compares the points of is over the domain with the model, and
+	// checks that its ranges are coalesced.
+	check := func(op string, is *IntervalSet, m model) {
+		var prev *Range
+		for r := range is.Ranges() {
+			if r.empty() || prev != nil && !gapBetween(prev.Hi, r.Lo) {
+				t.Fatalf("%s: ranges %v are not coalesced", op, slices.Collect(is.Ranges()))
+			}
+			prev = &r
+		}
+		for p := lo; p < hi; p++ {
+			if is.Contains(Int(p)) != (m[Int(p)] > 0) {
+				t.Fatalf("%s: Contains(%d) = %v in %v", op, p, is.Contains(Int(p)), slices.Collect(is.Ranges()))
+			}
+		}
+	}
+	// combine returns the model of the points for which keep holds, given
+	// whether they are in each of two models.
+	combine := func(am, bm model, keep func(x, y bool) bool) model {
+		m := make(model)
+		for p := lo; p < hi; p++ {
+			if keep(am[Int(p)] > 0, bm[Int(p)] > 0) {
+				m[Int(p)] = 1
+			}
+		}
+		return m
+	}
+	ops := []struct {
+		name string
+		op   func(a, b *IntervalSet) *IntervalSet
+		keep func(x, y bool) bool
+	}{
+		{"Union", (*IntervalSet).Union, func(x, y bool) bool { return x || y }},
+		{"Intersection", (*IntervalSet).Intersection, func(x, y bool) bool { return x && y }},
+		{"Difference", (*IntervalSet).Difference, func(x, y bool) bool { return x && !y }},
+		{"Complement", func(a, _ *IntervalSet) *IntervalSet { return a.Complement() }, func(x, _ bool) bool { return !x }},
+	}
+	for round := 0; round < 200; round++ {
+		a, am := random()
+		b, bm := random()
+		check("NewIntervalSet", a, am)
+		for _, op := range ops {
+			check(op.name, op.op(a, b), combine(am, bm, op.keep))
+		}
+		for r := range b.Ranges() {
+			a.Add(r)
+		}
+		check("Add", a, combine(am, bm, ops[0].keep))
+		for r := range b.Ranges() {
+			a.Remove(r)
+		}
+		check("Remove", a, combine(am, bm, ops[2].keep))
+	}
+}
+
+// testIntCodec encodes testInt values as varints.
+type testIntCodec struct{}
+
+func (testIntCodec) Append(buf []byte, v c.Comparable) ([]byte, error) {
+	return binary.AppendVarint(buf, int64(v.(testInt))), nil
+}
+
+func (testIntCodec) Decode(data []byte) (c.Comparable, error) {
+	x, k := binary.Varint(data)
+	if k != len(data) {
+		return nil, fmt.Errorf("bad varint % x", data)
+	}
+	return testInt(x), nil
+}
+
+func TestTreeSetBinary(t *testing.T) {
+	rnd := rand.New(rand.NewSource(5))
+	xs := rnd.Perm(3000)[:1000]
+	for _, impl := range []tree.TreeImplementation{tree.AVL_THREAD, tree.RB_THREAD, tree.BTREE} {
+		s := fill(TreeSetWithCodec(tree.NewTree(impl), testIntCodec{}), xs)
+		data, err := s.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", impl, err)
+		}
+		// Decoding keeps the tree of the set, and with it its views.
+		rt := tree.NewTree(impl)
+		rs := fill(TreeSetWithCodec(rt, testIntCodec{}), []int{-1, 5000})
+		var head RangedSet
+		if r, ok := rs.(RangedSet); ok {
+			head = r.HeadSet(testInt(100), false)
+		}
+		if err := rs.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s: %v", impl, err)
+		}
+		if rs.CompareTo(s) != 0 || rs.Size() != len(xs) {
+			t.Errorf("%s: decoded set differs", impl)
+		}
+		if rt.Size() != uint(len(xs)) {
+			t.Errorf("%s: decoded set no longer backed by its tree", impl)
+		}
+		if head != nil {
+			want := slices.DeleteFunc(contents(s), func(x int) bool { return x >= 100 })
+			if fmt.Sprint(contents(head)) != fmt.Sprint(want) {
+				t.Errorf("%s: view holds %v after decode, expected %v", impl, contents(head), want)
+			}
+		}
+		data[len(data)/2] ^= 1
+		if err := rs.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: corrupt stream decoded", impl)
+		}
+	}
+
+	// The codec of an AVL tree serves its sets and those derived from them.
+	at := tree.NewTree(tree.AVL_THREAD).(*tree.AvlTree)
+	at.SetCodec(testIntCodec{})
+	a := fill(TreeSet(at), xs[:10])
+	u := a.Union(fill(TreeSet(tree.NewTree(tree.AVL_THREAD)), xs[10:20]))
+	data, err := u.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ru := TreeSet(tree.NewTree(tree.AVL_THREAD))
+	if err := ru.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err == nil {
+		t.Error("set without a codec decoded a stream")
+	}
+	ru = TreeSet(at.Clone())
+	if err := ru.(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil || ru.CompareTo(u) != 0 {
+		t.Errorf("round trip through tree codec failed: %v", err)
+	}
+	if _, err := TreeSet(tree.NewTree(tree.RB_THREAD)).(encoding.BinaryMarshaler).MarshalBinary(); err == nil {
+		t.Error("set without a codec encoded")
+	}
+}
+
+func init() {
+	c.Register[testInt]("set.testInt")
+}
+
+func TestTreeSetDecodeView(t *testing.T) {
+	base := fill(TreeSet(tree.NewTree()), []int{1, 2, 3}).(RangedSet)
+	view := base.SubSet(testInt(1), testInt(3), true, true)
+	data, err := json.Marshal(fill(TreeSet(tree.NewTree()), []int{2, 5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, view); err == nil {
+		t.Error("decoded a value outside the range of a view")
+	}
+	if fmt.Sprint(contents(base)) != "[1 2 3]" {
+		t.Errorf("failed decode into a view left %v", contents(base))
+	}
+	data, _ = json.Marshal(fill(TreeSet(tree.NewTree()), []int{2}))
+	if err := json.Unmarshal(data, view); err != nil || fmt.Sprint(contents(base)) != "[2]" {
+		t.Errorf("decode into a view left %v, %v", contents(base), err)
+	}
+}
+
+func TestSetJSONGob(t *testing.T) {
+	shapes := []struct {
+		name string
+		s    Set
+		zero func() Set
+	}{
+		{"empty", Empty(), func() Set { return &emptySet{} }},
+		{"singleton", Singleton(testInt(7)), func() Set { return &singletonSet{} }},
+		{"pair", Pair(testInt(9), testInt(-2)), func() Set { return &pairSet{} }},
+		{"tree", fill(TreeSet(tree.NewTree(tree.RB_THREAD)), []int{4, 1, 8, 3, 6}), func() Set { return TreeSet(nil) }},
+		{"Int", TreeSet(tree.BuildFromSortedSlice([]c.Comparable{Int(1), Int(2), Int(40)})), func() Set { return TreeSet(tree.NewTree()) }},
+	}
+	for _, sh := range shapes {
+		data, err := json.Marshal(sh.s)
+		if err != nil {
+			t.Fatalf("%s: %v", sh.name, err)
+		}
+		rs := sh.zero()
+		if err := json.Unmarshal(data, rs); err != nil || rs.CompareTo(sh.s) != 0 {
+			t.Errorf("%s: JSON round trip of %s gave %v, %v", sh.name, data, slices.Collect(rs.All()), err)
+		}
+		ds, err := DecodeJSON(data)
+		if err != nil || ds.CompareTo(sh.s) != 0 || ds.Size() > 2 && fmt.Sprintf("%T", ds) != "*set.rangedTreeSet" {
+			t.Errorf("%s: DecodeJSON() gave %T %v, %v", sh.name, ds, slices.Collect(ds.All()), err)
+		}
+
+		// gob carries each shape through a field of interface type.
+		type holder struct{ S Set }
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(holder{sh.s}); err != nil {
+			t.Fatalf("%s: %v", sh.name, err)
+		}
+		var h holder
+		if err := gob.NewDecoder(&buf).Decode(&h); err != nil || h.S.CompareTo(sh.s) != 0 {
+			t.Errorf("%s: gob round trip gave %v, %v", sh.name, h.S, err)
+		}
+		if fmt.Sprintf("%T", h.S) != fmt.Sprintf("%T", sh.s) {
+			t.Errorf("%s: gob round trip changed %T to %T", sh.name, sh.s, h.S)
+		}
+	}
+
+	if string(must(json.Marshal(Pair(testInt(3), testInt(1))))) !=
+		`[{"type":"set.testInt","value":1},{"type":"set.testInt","value":3}]` {
+		t.Error("pair not encoded in ascending order")
+	}
+	two := must(json.Marshal(Pair(testInt(3), testInt(1))))
+	if err := json.Unmarshal(two, &singletonSet{}); err == nil {
+		t.Error("two elements decoded into a singleton set")
+	}
+	if err := json.Unmarshal(two, &emptySet{}); err == nil {
+		t.Error("two elements decoded into an empty set")
+	}
+	dup := []byte(`[{"type":"set.testInt","value":5},{"type":"set.testInt","value":5}]`)
+	if err := json.Unmarshal(dup, &singletonSet{}); err != nil {
+		t.Errorf("duplicate elements not merged: %v", err)
+	}
+	if err := json.Unmarshal(dup, &pairSet{}); err == nil {
+		t.Error("one distinct element decoded into a pair set")
+	}
+	if _, err := json.Marshal(Singleton(unregistered(1))); err == nil {
+		t.Error("unregistered element type encoded")
+	}
+}
+
+// unregistered is a Comparable type with no decoder registered.
+type unregistered int
+
+func (u unregistered) CompareTo(o c.Comparable) int8 {
+	return testInt(u).CompareTo(testInt(o.(unregistered)))
+}
+
+func must(data []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
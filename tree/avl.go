@@ -1,55 +1,181 @@
 package tree
 
-import c "github.com/dtromb/collections"
+import (
+	"cmp"
 
-// AvlTree is an implementation of an AVL Balanced Binary AvlTree, with threads.
-// O(1) iteration and O(ln(n)) time for other operations.
-type AvlTree struct {
-	size uint
-	root *avlNode
-	head *avlNode
-	tail *avlNode
+	c "github.com/dtromb/collections"
+)
+
+// AvlTreeOf is an implementation of an AVL Balanced Binary Tree, with threads,
+// over elements of any type T ordered by a comparison function.  O(1)
+// iteration and O(ln(n)) time for other operations.  The zero value is an
+// empty tree ordering its elements through the Comparable interface, which
+// they must then implement.
+type AvlTreeOf[T any] struct {
+	compareFn func(a, b T) int
+	augment   func(n *avlNode[T])
+	codec     c.Codec[T]
+	failFast  bool
+	mods      uint
+	size      uint
+	root      *avlNode[T]
+	head      *avlNode[T]
+	tail      *avlNode[T]
 }
 
-type treeCursor struct {
-	tree     *AvlTree
-	nextNode *avlNode
+// AvlTree is the AVL tree over Comparable values, and implements Tree.
+type AvlTree = AvlTreeOf[c.Comparable]
+
+type treeCursor[T any] struct {
+	tree     *AvlTreeOf[T]
+	nextNode *avlNode[T]
+	last     *avlNode[T]
 	end      bool
+	failFast bool
+	mods     uint
 }
 
-type avlNode struct {
-	data              c.Comparable
+type avlNode[T any] struct {
+	data              T
 	balance           int8
-	l, r, p, nxt, prv *avlNode
+	size              uint
+	l, r, p, nxt, prv *avlNode[T]
+}
+
+// NewAvlTreeOf creates an empty tree ordered by cmp, which must return a
+// negative value, zero or a positive value as a orders before, equal to or
+// after b.
+func NewAvlTreeOf[T any](cmp func(a, b T) int) *AvlTreeOf[T] {
+	return &AvlTreeOf[T]{compareFn: cmp}
+}
+
+// NewOrderedAvlTree creates an empty tree of values in their natural order.
+func NewOrderedAvlTree[T cmp.Ordered]() *AvlTreeOf[T] {
+	return &AvlTreeOf[T]{compareFn: cmp.Compare[T]}
+}
+
+// compare orders two elements with the tree's comparison function, or as
+// Comparable values if it has none.
+func (t *AvlTreeOf[T]) compare(a, b T) int {
+	if t.compareFn != nil {
+		return t.compareFn(a, b)
+	}
+	return int(any(a).(c.Comparable).CompareTo(any(b).(c.Comparable)))
+}
+
+// Comparator returns the function ordering the elements of the tree.
+func (t *AvlTreeOf[T]) Comparator() func(a, b T) int {
+	if t.compareFn != nil {
+		return t.compareFn
+	}
+	return t.compare
+}
+
+// empty returns a new empty tree with the same ordering, codec and cursor
+// mode as t.
+func (t *AvlTreeOf[T]) empty() *AvlTreeOf[T] {
+	return &AvlTreeOf[T]{compareFn: t.compareFn, augment: t.augment, codec: t.codec, failFast: t.failFast}
+}
+
+// reset empties the tree in place.
+func (t *AvlTreeOf[T]) reset() {
+	t.root, t.head, t.tail = nil, nil, nil
+	t.size = 0
+	t.mods++
+}
+
+// Clear removes every value from the tree, keeping its ordering, codec and
+// cursor mode.  Views of the tree become empty with it.
+func (t *AvlTreeOf[T]) Clear() {
+	t.reset()
+}
+
+// SetFailFast selects whether cursors opened on the tree from now on are
+// fail-fast.  A fail-fast cursor panics with a *c.ConcurrentModificationError
+// if it is used after the tree has been structurally modified (that is, a
+// value was added or removed) since the cursor was opened.  Cursors are not
+// fail-fast by default.
+func (t *AvlTreeOf[T]) SetFailFast(failFast bool) {
+	t.failFast = failFast
+}
+
+// checkMods panics if the tree has been modified since mods was recorded.
+func (t *AvlTreeOf[T]) checkMods(mods uint) {
+	if mods != t.mods {
+		panic(&c.ConcurrentModificationError{})
+	}
+}
+
+// count returns the number of nodes in the subtree rooted at n.
+func (n *avlNode[T]) count() uint {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+// resize recomputes the subtree size of n from its children.
+func (n *avlNode[T]) resize() {
+	n.size = n.l.count() + n.r.count() + 1
 }
 
-func (t *AvlTree) Has(data c.Comparable) bool {
+// update recomputes the subtree size of n from its children, along with its
+// augmented value if the tree has an augment function.  The augment function
+// of an augmented tree (such as IntervalTree) updates the summary held in the
+// data of n from the data of its children, and is called wherever the shape
+// of the subtree under a node changes, including in rotations.
+func (t *AvlTreeOf[T]) update(n *avlNode[T]) {
+	n.resize()
+	if t.augment != nil {
+		t.augment(n)
+	}
+}
+
+// augmentPath recomputes the augmented values on the path from n to the root
+// after the data of n has been replaced in place.
+func (t *AvlTreeOf[T]) augmentPath(n *avlNode[T]) {
+	if t.augment == nil {
+		return
+	}
+	for ; n != nil; n = n.p {
+		t.augment(n)
+	}
+}
+
+func (t *AvlTreeOf[T]) Has(data T) bool {
 	_, has := t.Lookup(c.LTE, data)
 	return has
 }
 
 // Lookup finds a value in the tree according to the given parameters.
-func (t *AvlTree) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+func (t *AvlTreeOf[T]) Lookup(lt c.LookupType, data T) (T, bool) {
+	var zero T
 	n, exact := t.lookupNode(lt, data)
-	if n == nil {
-		return nil, exact
+	if n == nil || (lt == c.EQ && !exact) {
+		return zero, exact
 	}
 	return n.data, exact
 }
 
-func (t *AvlTree) lookupNode(lt c.LookupType, data c.Comparable) (*avlNode, bool) {
+func (t *AvlTreeOf[T]) lookupNode(lt c.LookupType, data T) (*avlNode[T], bool) {
 	if t.root == nil {
 		return nil, false
 	}
 	cn := t.root
 	for {
-		r := data.CompareTo(cn.data)
+		r := t.compare(data, cn.data)
 		if r == 0 {
+			switch lt {
+			case c.LT:
+				return cn.prv, true
+			case c.GT:
+				return cn.nxt, true
+			}
 			return cn, true
 		}
 		if r < 0 {
 			if cn.l == nil {
-				if lt == c.GTE {
+				if ascending(lt) {
 					return cn, false
 				}
 				return cn.prv, false
@@ -57,7 +183,7 @@ func (t *AvlTree) lookupNode(lt c.LookupType, data c.Comparable) (*avlNode, bool
 			cn = cn.l
 		} else {
 			if cn.r == nil {
-				if lt == c.LTE {
+				if !ascending(lt) {
 					return cn, false
 				}
 				return cn.nxt, false
@@ -68,11 +194,66 @@ func (t *AvlTree) lookupNode(lt c.LookupType, data c.Comparable) (*avlNode, bool
 }
 
 // Size returns the number of elements in the tree.
-func (t *AvlTree) Size() uint {
+func (t *AvlTreeOf[T]) Size() uint {
 	return t.size
 }
 
-func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange bool) {
+// Select returns the value at (zero-based) position i in the tree order, or
+// the zero value (nil for AvlTree) if i is out of range.  O(ln(n)).
+func (t *AvlTreeOf[T]) Select(i uint) T {
+	var zero T
+	if i >= t.size {
+		return zero
+	}
+	cn := t.root
+	for {
+		ls := cn.l.count()
+		if i < ls {
+			cn = cn.l
+		} else if i > ls {
+			i -= ls + 1
+			cn = cn.r
+		} else {
+			return cn.data
+		}
+	}
+}
+
+// Rank returns the number of values in the tree ordered strictly less than
+// the argument, along with true if an equal value is present.  O(ln(n)).
+func (t *AvlTreeOf[T]) Rank(data T) (uint, bool) {
+	var rank uint
+	cn := t.root
+	for cn != nil {
+		r := t.compare(data, cn.data)
+		if r == 0 {
+			return rank + cn.l.count(), true
+		}
+		if r < 0 {
+			cn = cn.l
+		} else {
+			rank += cn.l.count() + 1
+			cn = cn.r
+		}
+	}
+	return rank, false
+}
+
+// CountRange returns the number of values x in the tree with lo <= x <= hi.
+// O(ln(n)).
+func (t *AvlTreeOf[T]) CountRange(lo, hi T) uint {
+	if t.compare(lo, hi) > 0 {
+		return 0
+	}
+	lr, _ := t.Rank(lo)
+	hr, has := t.Rank(hi)
+	if has {
+		hr++
+	}
+	return hr - lr
+}
+
+func (t *AvlTreeOf[T]) rebalanceAtNode(cn *avlNode[T]) (newParent *avlNode[T], heightChange bool) {
 	switch cn.balance {
 	case -2:
 		{
@@ -99,6 +280,8 @@ func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange
 						lrn.p = cn
 					}
 					cn.balance = 0
+					t.update(cn)
+					t.update(ln)
 					return ln, true
 				}
 			case 0:
@@ -121,6 +304,8 @@ func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange
 					cn.balance = -1
 					ln.r = cn
 					cn.p = ln
+					t.update(cn)
+					t.update(ln)
 					return ln, false
 				}
 			case 1:
@@ -159,6 +344,9 @@ func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange
 					lrn.r = cn
 					cn.p = lrn
 					lrn.balance = 0
+					t.update(ln)
+					t.update(cn)
+					t.update(lrn)
 					return lrn, true
 				}
 			}
@@ -203,6 +391,9 @@ func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange
 					rn.p = rln
 					rln.r = rn
 					rln.balance = 0
+					t.update(cn)
+					t.update(rn)
+					t.update(rln)
 					return rln, true
 				}
 			case 0:
@@ -225,6 +416,8 @@ func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange
 					cn.p = rn
 					rn.l = cn
 					cn.balance = 1
+					t.update(cn)
+					t.update(rn)
 					return rn, false
 				}
 			case 1:
@@ -247,6 +440,8 @@ func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange
 					cn.balance = 0
 					cn.p = rn
 					rn.l = cn
+					t.update(cn)
+					t.update(rn)
 					return rn, true
 				}
 			}
@@ -256,21 +451,24 @@ func (t *AvlTree) rebalanceAtNode(cn *avlNode) (newParent *avlNode, heightChange
 }
 
 // Insert adds or replaces a value in the tree.  If there is already an equal value,
-// it is replaced and the old value returned.  Otherwise, nil is returned.
-func (t *AvlTree) Insert(data c.Comparable) c.Comparable {
+// it is replaced and the old value returned.  Otherwise, the zero value is returned.
+func (t *AvlTreeOf[T]) Insert(data T) T {
+	var zero T
 	if t.root == nil {
-		t.root = &avlNode{
+		t.root = &avlNode[T]{
 			data: data,
 		}
+		t.update(t.root)
 		t.size++
+		t.mods++
 		t.head = t.root
 		t.tail = t.root
-		return nil
+		return zero
 	}
 	cn := t.root
-	var r int8
+	var r int
 	for {
-		r = data.CompareTo(cn.data)
+		r = t.compare(data, cn.data)
 		if r == 0 {
 			break
 		}
@@ -287,11 +485,12 @@ func (t *AvlTree) Insert(data c.Comparable) c.Comparable {
 		}
 	}
 	if r == 0 {
-		oldData := cn.data
+		old := cn.data
 		cn.data = data
-		return oldData
+		t.augmentPath(cn)
+		return old
 	}
-	nn := &avlNode{
+	nn := &avlNode[T]{
 		data: data,
 		p:    cn,
 	}
@@ -319,12 +518,25 @@ func (t *AvlTree) Insert(data c.Comparable) c.Comparable {
 		cn.balance++
 	}
 	t.size++
+	t.mods++
+	t.update(nn)
+	for an := cn; an != nil; an = an.p {
+		t.update(an)
+	}
+	t.growAtNode(cn)
+	return zero
+}
+
+// growAtNode restores balance after the subtree under one child of cn has
+// grown in height, with cn.balance already adjusted.  It returns true if the
+// height of the whole tree increased.
+func (t *AvlTreeOf[T]) growAtNode(cn *avlNode[T]) bool {
 	for {
 		switch cn.balance {
 		case 0:
 			{
 				// Depth did not increase, we are done.
-				return nil
+				return false
 			}
 		case -1:
 			fallthrough
@@ -339,7 +551,7 @@ func (t *AvlTree) Insert(data c.Comparable) c.Comparable {
 					}
 					cn = cn.p
 				} else {
-					return nil // At root, done.
+					return true // At root, done.
 				}
 			}
 		case -2:
@@ -349,7 +561,7 @@ func (t *AvlTree) Insert(data c.Comparable) c.Comparable {
 				np, changed := t.rebalanceAtNode(cn)
 				if changed {
 					// Change offset the insertion height change, we're done.
-					return nil
+					return false
 				}
 				cn = np
 			}
@@ -359,33 +571,46 @@ func (t *AvlTree) Insert(data c.Comparable) c.Comparable {
 
 // Delete removes an element from the tree.  If the argument is found, the
 // canonical value from the tree is returned along with boolean true.  If not,
-// the pair (nil,false) is returned.
-func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
+// the zero value and false is returned.
+func (t *AvlTreeOf[T]) Delete(data T) (T, bool) {
+	var zero T
+	cn := t.findNode(data)
+	if cn == nil {
+		return zero, false
+	}
+	t.deleteNode(cn)
+	return cn.data, true
+}
+
+// findNode returns the node holding a value equal to data, or nil.
+func (t *AvlTreeOf[T]) findNode(data T) *avlNode[T] {
 	if t.root == nil {
-		return nil, false
+		return nil
 	}
-	var returnVal c.Comparable
 	cn := t.root
 	for {
-		r := cn.data.CompareTo(data)
+		r := t.compare(cn.data, data)
 		//fmt.Printf("%s %d %d\n", dumpNode(cn), data, r)
 		if r == 0 {
-			returnVal = cn.data
-			break
+			return cn
 		} else if r > 0 {
 			if cn.l == nil {
-				return nil, false
+				return nil
 			}
 			cn = cn.l
 		} else {
 			if cn.r == nil {
-				return nil, false
+				return nil
 			}
 			cn = cn.r
 		}
 	}
+}
 
-	var bn *avlNode
+// deleteNode unlinks cn from the tree.  Nodes are relinked rather than having
+// their data swapped, so that cursors holding other nodes remain valid.
+func (t *AvlTreeOf[T]) deleteNode(cn *avlNode[T]) {
+	var bn *avlNode[T]
 
 	// cn is the node to delete.
 	if cn.l != nil { // cn's prv is cn.l or under cn.l
@@ -484,8 +709,11 @@ func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
 		//fmt.Println("C4")
 		if cn.p == nil {
 			t.root = nil
+			t.head = nil
+			t.tail = nil
 			t.size--
-			return returnVal, true
+			t.mods++
+			return
 		}
 		bn = cn.p
 		if bn.l == cn {
@@ -497,6 +725,11 @@ func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
 		}
 	}
 
+	// Every subtree whose membership changed lies on the path from bn up.
+	for an := bn; an != nil; an = an.p {
+		t.update(an)
+	}
+
 	if cn.prv != nil {
 		cn.prv.nxt = cn.nxt
 	} else {
@@ -511,6 +744,7 @@ func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
 	cn.prv = nil
 
 	t.size--
+	t.mods++
 
 	for {
 		switch bn.balance {
@@ -518,7 +752,7 @@ func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
 			{ // subtree has lost height, propagate upward
 				//fmt.Println("B0")
 				if bn.p == nil {
-					return returnVal, true
+					return
 				}
 				if bn.p.l == bn {
 					bn.p.balance++
@@ -533,7 +767,7 @@ func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
 			{
 				//fmt.Println("B1")
 				// subtree has not lost height, we are done
-				return returnVal, true
+				return
 			}
 		case 2:
 			fallthrough
@@ -542,7 +776,7 @@ func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
 				//fmt.Println("B2")
 				np, changed := t.rebalanceAtNode(bn)
 				if !changed {
-					return returnVal, true
+					return
 				}
 				bn = np
 			}
@@ -550,63 +784,69 @@ func (t *AvlTree) Delete(data c.Comparable) (c.Comparable, bool) {
 	}
 }
 
-// First opens a cirsor positioned before the first value in the tree.
-func (t *AvlTree) First() c.Cursor {
-	return &treeCursor{
-		tree:     t,
-		nextNode: t.head,
-	}
+// First opens a cursor positioned before the first value in the tree.
+func (t *AvlTreeOf[T]) First() c.CursorOf[T] {
+	return t.cursor(t.head, false)
 }
 
-// Last opens a cirsor positioned after the last value in the tree.
-func (t *AvlTree) Last() c.Cursor {
-	return &treeCursor{
-		tree:     t,
-		nextNode: nil,
-		end:      true,
-	}
+// Last opens a cursor positioned after the last value in the tree.
+func (t *AvlTreeOf[T]) Last() c.CursorOf[T] {
+	return t.cursor(nil, true)
 }
 
 // GetCursor opens a cursor whose first value will be the value that would have
 // been returned by an equivalent Lookup() call.  The iterator is bidirectional
-// and can range past the start of the search.  It is not fail-fast - changes
-// in the tree will change its behavior.
-func (t *AvlTree) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+// and can range past the start of the search.  Unless SetFailFast() has been
+// called it is not fail-fast - changes in the tree will change its behavior.
+func (t *AvlTreeOf[T]) GetCursor(lt c.LookupType, data T) (c.CursorOf[T], bool) {
 	n, exact := t.lookupNode(lt, data)
-	tc := &treeCursor{
+	return t.cursor(n, n == nil && ascending(lt)), exact
+}
+
+func (t *AvlTreeOf[T]) cursor(n *avlNode[T], end bool) *treeCursor[T] {
+	return &treeCursor[T]{
 		tree:     t,
 		nextNode: n,
+		end:      end,
+		failFast: t.failFast,
+		mods:     t.mods,
 	}
-	if tc.nextNode == nil {
-		if lt == c.GTE {
-			tc.end = true
-		}
-	}
-	return tc, exact
 }
 
 // HasNext checks for the availability of a next data value from the cursor.
-func (c *treeCursor) HasNext() bool {
+func (c *treeCursor[T]) HasNext() bool {
+	if c.failFast {
+		c.tree.checkMods(c.mods)
+	}
 	return c.nextNode != nil ||
 		(c.nextNode == nil && !c.end && c.tree.size > 0)
 }
 
 // HasPrev checks for the availability of a previous data value from the cursor.
-func (c *treeCursor) HasPrev() bool {
+func (c *treeCursor[T]) HasPrev() bool {
+	if c.failFast {
+		c.tree.checkMods(c.mods)
+	}
 	return (c.nextNode != nil && c.nextNode.prv != nil) ||
 		(c.nextNode == nil && c.end && c.tree.size > 0)
 }
 
 // Next retrieves the next value from the cursor.
-func (c *treeCursor) Next() c.Comparable {
+func (c *treeCursor[T]) Next() T {
+	var zero T
+	if c.failFast {
+		c.tree.checkMods(c.mods)
+	}
 	if c.nextNode == nil {
 		if c.end {
-			return nil
+			c.last = nil
+			return zero
 		}
 		c.nextNode = c.tree.head
 	}
+	c.last = c.nextNode
 	if c.nextNode == nil {
-		return nil
+		return zero
 	}
 	val := c.nextNode.data
 	c.nextNode = c.nextNode.nxt
@@ -620,20 +860,59 @@ func (c *treeCursor) Next() c.Comparable {
 // what it sounds like - if you "switch directions", Prev() will return the previous
 // value returned by Next() - **not the one before that** in the order.  And vice
 // versa...)
-func (c *treeCursor) Prev() c.Comparable {
+func (c *treeCursor[T]) Prev() T {
+	var zero T
+	if c.failFast {
+		c.tree.checkMods(c.mods)
+	}
 	if c.nextNode == nil {
-		if c.end == true {
+		if c.end && c.tree.tail != nil {
 			c.nextNode = c.tree.tail
-			val := c.nextNode.data
-			c.nextNode = c.nextNode.prv
 			c.end = false
-			return val
+			c.last = c.nextNode
+			return c.nextNode.data
 		}
-		return nil
+		c.last = nil
+		return zero
 	}
 	c.nextNode = c.nextNode.prv
+	c.last = c.nextNode
 	if c.nextNode == nil {
-		return nil
+		return zero
 	}
 	return c.nextNode.data
 }
+
+// Remove deletes the value most recently returned from the tree, leaving the
+// cursor valid.  It does not make a fail-fast cursor fail.
+func (c *treeCursor[T]) Remove() {
+	if c.failFast {
+		c.tree.checkMods(c.mods)
+	}
+	if c.last == nil {
+		panic("Remove() without a current value")
+	}
+	if c.nextNode == c.last {
+		c.nextNode = c.last.nxt
+		c.end = c.nextNode == nil
+	}
+	c.tree.deleteNode(c.last)
+	c.last = nil
+	c.mods = c.tree.mods
+}
+
+// Set replaces the value most recently returned with v, which must compare
+// equal to it.
+func (c *treeCursor[T]) Set(v T) {
+	if c.failFast {
+		c.tree.checkMods(c.mods)
+	}
+	if c.last == nil {
+		panic("Set() without a current value")
+	}
+	if c.tree.compare(v, c.last.data) != 0 {
+		panic("Set() value does not compare equal")
+	}
+	c.last.data = v
+	c.tree.augmentPath(c.last)
+}
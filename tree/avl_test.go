@@ -3,13 +3,15 @@ package tree
 import (
 	"fmt"
 	c "github.com/dtromb/collections"
+	"math/bits"
 	"math/rand"
 	"runtime"
 	"strconv"
+	"strings"
 	"testing"
 )
 
-func treeCounts(n *avlNode) (leftDepth int, rightDepth int, size int) {
+func treeCounts(n *avlNode[c.Comparable]) (leftDepth int, rightDepth int, size int) {
 	var lld, lrd, ls, rld, rrd, rs, ld, rd, s int
 	if n == nil {
 		return 0, 0, 0
@@ -34,7 +36,7 @@ func treeCounts(n *avlNode) (leftDepth int, rightDepth int, size int) {
 	return ld, rd, s
 }
 
-func checkStructuralIntegrity(t *Tree, n *avlNode) {
+func checkStructuralIntegrity(t *AvlTree, n *avlNode[c.Comparable]) {
 	if n.l != nil {
 		if n.l.p != n {
 			panic("left child's parent is not self")
@@ -93,7 +95,7 @@ func checkStructuralIntegrity(t *Tree, n *avlNode) {
 	}
 }
 
-func inorderSucc(n *avlNode) *avlNode {
+func inorderSucc(n *avlNode[c.Comparable]) *avlNode[c.Comparable] {
 	if n.r != nil {
 		cn := n.r
 		for cn.l != nil {
@@ -111,7 +113,7 @@ func inorderSucc(n *avlNode) *avlNode {
 	return nil
 }
 
-func inorderPred(n *avlNode) *avlNode {
+func inorderPred(n *avlNode[c.Comparable]) *avlNode[c.Comparable] {
 	if n.l != nil {
 		cn := n.l
 		for cn.r != nil {
@@ -129,7 +131,7 @@ func inorderPred(n *avlNode) *avlNode {
 	return nil
 }
 
-func checkLinks(n *avlNode) {
+func checkLinks(n *avlNode[c.Comparable]) {
 	if inorderPred(n) != n.prv {
 		fmt.Printf("%d %s\n", n.data.(ComparableInt), strnode(inorderPred(n)))
 		panic("incorrect predecessor link")
@@ -139,7 +141,7 @@ func checkLinks(n *avlNode) {
 	}
 }
 
-func checkOrder(n *avlNode) {
+func checkOrder(n *avlNode[c.Comparable]) {
 	if n.l != nil {
 		if n.data.CompareTo(n.l.data) <= 0 {
 			panic("left child order violation")
@@ -175,7 +177,7 @@ func (ci ComparableInt) CompareTo(o c.Comparable) int8 {
 	return 0
 }
 
-func checkBalance(n *avlNode) {
+func checkBalance(n *avlNode[c.Comparable]) {
 	ld, rd, _ := treeCounts(n)
 	balance := rd - ld
 	if n.balance != int8(balance) {
@@ -186,14 +188,21 @@ func checkBalance(n *avlNode) {
 	}
 }
 
-func checkNode(t *Tree, n *avlNode) {
+func checkSize(n *avlNode[c.Comparable]) {
+	if n.size != n.l.count()+n.r.count()+1 {
+		panic("incorrect subtree size record")
+	}
+}
+
+func checkNode(t *AvlTree, n *avlNode[c.Comparable]) {
 	checkStructuralIntegrity(t, n)
 	checkOrder(n)
 	checkLinks(n)
 	checkBalance(n)
+	checkSize(n)
 }
 
-func checkNodesRecursive(t *Tree, n *avlNode) {
+func checkNodesRecursive(t *AvlTree, n *avlNode[c.Comparable]) {
 	if n == nil {
 		return
 	}
@@ -202,7 +211,7 @@ func checkNodesRecursive(t *Tree, n *avlNode) {
 	checkNodesRecursive(t, n.r)
 }
 
-func checkTree(t *Tree) {
+func checkTree(t *AvlTree) {
 	_, _, size := treeCounts(t.root)
 	if size != int(t.Size()) {
 		panic("incorrect size in tree")
@@ -210,14 +219,14 @@ func checkTree(t *Tree) {
 	checkNodesRecursive(t, t.root)
 }
 
-func strnode(n *avlNode) string {
+func strnode(n *avlNode[c.Comparable]) string {
 	if n == nil {
 		return "nil"
 	}
 	return strconv.Itoa(int(n.data.(ComparableInt)))
 }
 
-func dumpNode(n *avlNode) string {
+func dumpNode(n *avlNode[c.Comparable]) string {
 	if n == nil {
 		return fmt.Sprintf("nil")
 	}
@@ -226,7 +235,7 @@ func dumpNode(n *avlNode) string {
 		strnode(n.prv), strnode(n.nxt), n.balance)
 }
 
-func dumpNodeRecursive(n *avlNode) {
+func dumpNodeRecursive(n *avlNode[c.Comparable]) {
 	fmt.Println(dumpNode(n))
 	if n.l != nil {
 		dumpNodeRecursive(n.l)
@@ -236,12 +245,12 @@ func dumpNodeRecursive(n *avlNode) {
 	}
 }
 
-func dumpTree(t *Tree) {
+func dumpTree(t *AvlTree) {
 	dumpNodeRecursive(t.root)
 }
 
 func TestAvl(t *testing.T) {
-	tree := &Tree{}
+	tree := &AvlTree{}
 	defer func() {
 		if r := recover(); r != nil {
 			t.Error(r)
@@ -296,7 +305,7 @@ func TestAvl(t *testing.T) {
 }
 
 func TestAvl2(t *testing.T) {
-	tree := &Tree{}
+	tree := &AvlTree{}
 	defer func() {
 		if r := recover(); r != nil {
 			t.Error(r)
@@ -341,3 +350,379 @@ func TestAvl2(t *testing.T) {
 		panic("incorrect size after deletions")
 	}
 }
+
+func TestAvlRank(t *testing.T) {
+	tree := &AvlTree{}
+	var N = 2000
+	for i := 0; i < N; i++ {
+		tree.Insert(ComparableInt(2 * ((i * 7919) % N)))
+	}
+	checkTree(tree)
+	for i := 0; i < N; i++ {
+		if v := tree.Select(uint(i)); v != ComparableInt(2*i) {
+			t.Fatalf("Select(%d) = %v, expected %d", i, v, 2*i)
+		}
+		r, has := tree.Rank(ComparableInt(2 * i))
+		if !has || r != uint(i) {
+			t.Fatalf("Rank(%d) = %d,%v", 2*i, r, has)
+		}
+		r, has = tree.Rank(ComparableInt(2*i + 1))
+		if has || r != uint(i+1) {
+			t.Fatalf("Rank(%d) = %d,%v", 2*i+1, r, has)
+		}
+	}
+	if tree.Select(uint(N)) != nil {
+		t.Error("Select past end returned a value")
+	}
+	if n := tree.CountRange(ComparableInt(10), ComparableInt(20)); n != 6 {
+		t.Errorf("CountRange(10,20) = %d, expected 6", n)
+	}
+	if n := tree.CountRange(ComparableInt(11), ComparableInt(19)); n != 4 {
+		t.Errorf("CountRange(11,19) = %d, expected 4", n)
+	}
+	if n := tree.CountRange(ComparableInt(20), ComparableInt(10)); n != 0 {
+		t.Errorf("CountRange(20,10) = %d, expected 0", n)
+	}
+	for i := 0; i < N; i += 3 {
+		tree.Delete(ComparableInt(2 * i))
+	}
+	checkTree(tree)
+	var i uint
+	for cur := tree.First(); cur.HasNext(); i++ {
+		if v := cur.Next(); tree.Select(i) != v {
+			t.Fatalf("Select(%d) disagrees with cursor after deletions", i)
+		}
+	}
+}
+
+func avlRange(lo, hi, step int) *AvlTree {
+	tree := &AvlTree{}
+	for i := lo; i < hi; i += step {
+		tree.Insert(ComparableInt(i))
+	}
+	return tree
+}
+
+func checkAvlValues(t *testing.T, tree *AvlTree, expected []int) {
+	checkTree(tree)
+	model := make(map[ComparableInt]bool)
+	var max int
+	for _, x := range expected {
+		model[ComparableInt(x)] = true
+		max = x + 1
+	}
+	checkContents(t, tree, model, max)
+}
+
+func TestAvlSplitJoin(t *testing.T) {
+	var N = 300
+	for pivot := -1; pivot <= N+1; pivot++ {
+		tree := avlRange(0, N, 1)
+		left, right := tree.Split(ComparableInt(pivot))
+		if tree.Size() != 0 {
+			t.Fatal("split tree is not empty")
+		}
+		var lv, rv []int
+		for i := 0; i < N; i++ {
+			if i < pivot {
+				lv = append(lv, i)
+			} else {
+				rv = append(rv, i)
+			}
+		}
+		checkAvlValues(t, left, lv)
+		checkAvlValues(t, right, rv)
+		tree.Join(left, right)
+		if left.Size() != 0 || right.Size() != 0 {
+			t.Fatal("joined trees are not empty")
+		}
+		checkAvlValues(t, tree, append(lv, rv...))
+	}
+	// Join trees of very different heights in both orders.
+	for _, n := range []int{0, 1, 2, 5, 40, 1000} {
+		small, large := avlRange(0, n, 1), avlRange(n, n+2000, 1)
+		small.Join(small, large)
+		checkAvlValues(t, small, rangeInts(0, n+2000, 1))
+		small, large = avlRange(2000, 2000+n, 1), avlRange(0, 2000, 1)
+		large.Join(large, small)
+		checkAvlValues(t, large, rangeInts(0, n+2000, 1))
+	}
+}
+
+func rangeInts(lo, hi, step int) []int {
+	var r []int
+	for i := lo; i < hi; i += step {
+		r = append(r, i)
+	}
+	return r
+}
+
+func TestAvlSetAlgebra(t *testing.T) {
+	var N = 3000
+	for _, step := range []int{1, 2, 3, 7, 100} {
+		a, b := avlRange(0, N, 2), avlRange(N/3, N*2, step)
+		inA := func(i int) bool { return i >= 0 && i < N && i%2 == 0 }
+		inB := func(i int) bool { return i >= N/3 && i < N*2 && (i-N/3)%step == 0 }
+		var union, inter, diff []int
+		for i := 0; i < N*2; i++ {
+			if inA(i) || inB(i) {
+				union = append(union, i)
+			}
+			if inA(i) && inB(i) {
+				inter = append(inter, i)
+			}
+			if inA(i) && !inB(i) {
+				diff = append(diff, i)
+			}
+		}
+		u := a.Clone()
+		checkAvlValues(t, u, rangeInts(0, N, 2))
+		u.UnionWith(b.Clone())
+		checkAvlValues(t, u, union)
+		i := a.Clone()
+		i.IntersectWith(b.Clone())
+		checkAvlValues(t, i, inter)
+		a.DifferenceWith(b)
+		checkAvlValues(t, a, diff)
+	}
+}
+
+func TestAvlGeneric(t *testing.T) {
+	var N = 2000
+	tree := NewOrderedAvlTree[int]()
+	for i := 0; i < N; i++ {
+		tree.Insert((i * 7919) % N)
+	}
+	tree.Delete(N / 2)
+	if tree.Size() != uint(N-1) || tree.Has(N/2) || !tree.Has(N/2+1) {
+		t.Error("generic tree has wrong contents")
+	}
+	if v, exact := tree.Lookup(c.LTE, N/2); exact || v != N/2-1 {
+		t.Errorf("LTE lookup returned %d", v)
+	}
+	expect := 0
+	cur := tree.First()
+	for cur.HasNext() {
+		if expect == N/2 {
+			expect++
+		}
+		if v := cur.Next(); v != expect {
+			t.Fatalf("cursor returned %d, expected %d", v, expect)
+		}
+		expect++
+	}
+	rev := NewAvlTreeOf(func(a, b string) int { return strings.Compare(b, a) })
+	for _, s := range []string{"b", "c", "a"} {
+		rev.Insert(s)
+	}
+	if rev.Select(0) != "c" || rev.Select(2) != "a" {
+		t.Error("custom comparator not applied")
+	}
+}
+
+func TestAvlIterators(t *testing.T) {
+	var N = 500
+	tree := NewOrderedAvlTree[int]()
+	for i := 0; i < N; i++ {
+		tree.Insert(2 * ((i * 7919) % N))
+	}
+	collect := func(seq func(func(int) bool)) []int {
+		var r []int
+		for v := range seq {
+			r = append(r, v)
+		}
+		return r
+	}
+	check := func(name string, got []int, lo, hi, step int) {
+		want := rangeInts(lo, hi, step)
+		if step < 0 {
+			want = nil
+			for i := lo; i > hi; i += step {
+				want = append(want, i)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s yielded %v, expected %v", name, got, want)
+		}
+	}
+	check("All", collect(tree.All()), 0, 2*N, 2)
+	check("Backward", collect(tree.Backward()), 2*N-2, -1, -2)
+	check("Range", collect(tree.Range(11, 40)), 12, 41, 2)
+	check("Range (empty)", collect(tree.Range(40, 11)), 0, 0, 1)
+	check("From GTE", collect(tree.From(c.GTE, 2*N-5)), 2*N-4, 2*N, 2)
+	check("From LTE", collect(tree.From(c.LTE, 2*N-5)), 2*N-6, 2*N, 2)
+	check("From LTE (none)", collect(tree.From(c.LTE, -1)), 0, 2*N, 2)
+	check("From GTE (none)", collect(tree.From(c.GTE, 2*N)), 0, 0, 1)
+	check("Values", collect(c.Values(tree.First())), 0, 2*N, 2)
+	check("FromSeq", collect(c.Values(c.FromSeq(tree.All()))), 0, 2*N, 2)
+
+	// Deleting the yielded value must not disturb the iteration.
+	for v := range tree.All() {
+		if v%4 == 0 {
+			tree.Delete(v)
+		}
+	}
+	check("All (after delete)", collect(tree.All()), 2, 2*N, 4)
+}
+
+func TestAvlFailFast(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if _, ok := recover().(*c.ConcurrentModificationError); !ok {
+				t.Errorf("%s did not fail fast", name)
+			}
+		}()
+		f()
+	}
+	tree := avlRange(0, 100, 1)
+	tree.SetFailFast(true)
+	cur := tree.First()
+	cur.Next()
+	tree.Insert(ComparableInt(50)) // replacement is not a structural change
+	cur.Next()
+	tree.Insert(ComparableInt(100))
+	expectPanic("Next", func() { cur.Next() })
+	cur, _ = tree.GetCursor(c.GTE, ComparableInt(10))
+	tree.Delete(ComparableInt(10))
+	expectPanic("Prev", func() { cur.Prev() })
+	cur = tree.Last()
+	left, right := tree.Split(ComparableInt(20))
+	expectPanic("HasPrev", func() { cur.HasPrev() })
+	cur = right.First()
+	right.UnionWith(left)
+	expectPanic("HasNext", func() { cur.HasNext() })
+	mc := right.First().(c.MutableCursor)
+	mc.Next()
+	mc.Remove()
+	if v := mc.Next(); v != ComparableInt(1) {
+		t.Errorf("cursor returned %v after its own Remove(), expected 1", v)
+	}
+
+}
+
+func TestAvlSubTree(t *testing.T) {
+	var N = 60
+	tree := avlRange(0, 2*N, 2)
+	type bound struct {
+		x   int
+		inc bool
+	}
+	var bounds []bound
+	for _, x := range []int{-3, 0, 1, 10, 11, 40, 2*N - 2, 2*N + 5} {
+		bounds = append(bounds, bound{x, true}, bound{x, false})
+	}
+	all := func(cur c.Cursor, forward bool) []c.Comparable {
+		var r []c.Comparable
+		for forward && cur.HasNext() {
+			r = append(r, cur.Next())
+		}
+		for !forward && cur.HasPrev() {
+			r = append(r, cur.Prev())
+		}
+		return r
+	}
+	lookups := []c.LookupType{c.GTE, c.LTE, c.LT, c.GT, c.EQ}
+	compare := func(name string, view, ref Tree) {
+		if view.Size() != ref.Size() {
+			t.Fatalf("%s: size %d, expected %d", name, view.Size(), ref.Size())
+		}
+		if fmt.Sprint(all(view.First(), true)) != fmt.Sprint(all(ref.First(), true)) ||
+			fmt.Sprint(all(view.Last(), false)) != fmt.Sprint(all(ref.Last(), false)) {
+			t.Fatalf("%s: iteration differs from the reference", name)
+		}
+		for x := -4; x < 2*N+6; x++ {
+			k := ComparableInt(x)
+			if view.Has(k) != ref.Has(k) {
+				t.Fatalf("%s: Has(%d) differs", name, x)
+			}
+			for _, lt := range lookups {
+				v, exact := view.Lookup(lt, k)
+				rv, rexact := ref.Lookup(lt, k)
+				if v != rv || exact != rexact {
+					t.Fatalf("%s: Lookup(%d, %d) = %v,%v, expected %v,%v", name, lt, x, v, exact, rv, rexact)
+				}
+				vc, _ := view.GetCursor(lt, k)
+				rc, _ := ref.GetCursor(lt, k)
+				if v, rv := vc.Next(), rc.Next(); v != rv {
+					t.Fatalf("%s: GetCursor(%d, %d).Next() = %v, expected %v", name, lt, x, v, rv)
+				}
+				if v, rv := vc.Prev(), rc.Prev(); v != rv {
+					t.Fatalf("%s: GetCursor(%d, %d).Prev() = %v, expected %v", name, lt, x, v, rv)
+				}
+				if fmt.Sprint(all(vc, false)) != fmt.Sprint(all(rc, false)) {
+					t.Fatalf("%s: GetCursor(%d, %d) backward iteration differs", name, lt, x)
+				}
+			}
+		}
+	}
+	reference := func(in func(int) bool) *AvlTree {
+		ref := &AvlTree{}
+		for i := 0; i < 2*N; i += 2 {
+			if in(i) {
+				ref.Insert(ComparableInt(i))
+			}
+		}
+		return ref
+	}
+	for _, lo := range bounds {
+		aboveLo := func(x int) bool { return x > lo.x || (x == lo.x && lo.inc) }
+		compare(fmt.Sprintf("TailTree(%v)", lo), tree.TailTree(ComparableInt(lo.x), lo.inc), reference(aboveLo))
+		for _, hi := range bounds {
+			belowHi := func(x int) bool { return x < hi.x || (x == hi.x && hi.inc) }
+			name := fmt.Sprintf("SubTree(%v, %v)", lo, hi)
+			sub := tree.SubTree(ComparableInt(lo.x), ComparableInt(hi.x), lo.inc, hi.inc)
+			compare(name, sub, reference(func(x int) bool { return aboveLo(x) && belowHi(x) }))
+			if lo == bounds[0] {
+				compare(fmt.Sprintf("HeadTree(%v)", hi), tree.HeadTree(ComparableInt(hi.x), hi.inc), reference(belowHi))
+			}
+		}
+	}
+
+	// Views are live, and narrow when nested.
+	view := tree.SubTree(ComparableInt(10), ComparableInt(20), true, false)
+	tree.Insert(ComparableInt(15))
+	if !view.Has(ComparableInt(15)) || view.Size() != 6 {
+		t.Error("view does not reflect insertion into the tree")
+	}
+	view.Delete(ComparableInt(10))
+	if tree.Has(ComparableInt(10)) {
+		t.Error("deletion through the view did not reach the tree")
+	}
+	if n := view.TailTree(ComparableInt(14), false).HeadTree(ComparableInt(30), true).Size(); n != 3 {
+		t.Errorf("nested view has size %d, expected 3", n)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("insertion outside the view did not panic")
+		}
+	}()
+	view.Insert(ComparableInt(20))
+}
+
+func TestAvlBuildFromSorted(t *testing.T) {
+	for n := 0; n < 300; n++ {
+		var vals []c.Comparable
+		for i := 0; i < n; i++ {
+			vals = append(vals, ComparableInt(i))
+			if i%7 == 3 {
+				vals = append(vals, ComparableInt(i))
+			}
+		}
+		tree := BuildFromSortedSlice(vals)
+		checkAvlValues(t, tree, rangeInts(0, n, 1))
+		if n > 0 && height(tree.root) != bits.Len(uint(n)) {
+			t.Fatalf("tree of %d values has height %d", n, height(tree.root))
+		}
+		tree.Insert(ComparableInt(n))
+		tree.Delete(ComparableInt(0))
+		checkAvlValues(t, tree, rangeInts(1, n+1, 1))
+	}
+	tree := BuildFromSorted(avlRange(0, 1000, 3).First())
+	checkAvlValues(t, tree, rangeInts(0, 1000, 3))
+	defer func() {
+		if recover() == nil {
+			t.Error("out of order input did not panic")
+		}
+	}()
+	BuildFromSortedSlice([]c.Comparable{ComparableInt(1), ComparableInt(3), ComparableInt(2)})
+}
@@ -0,0 +1,83 @@
+package tree
+
+import (
+	"math/bits"
+
+	c "github.com/dtromb/collections"
+)
+
+// BuildFromSorted creates a tree holding the values remaining in a cursor,
+// which must return them in ascending order.  Runs of equal values are
+// reduced to the last of the run, as if each had been inserted in turn.  The
+// tree is perfectly balanced and is built in O(n) time.  It panics if the
+// input is out of order.
+func BuildFromSorted(cur c.Cursor) *AvlTree {
+	var vals []c.Comparable
+	for cur.HasNext() {
+		vals = append(vals, cur.Next())
+	}
+	return BuildFromSortedSlice(vals)
+}
+
+// BuildFromSortedSlice creates a tree holding the values of a slice sorted in
+// ascending order, as for BuildFromSorted.  The slice is not modified.
+func BuildFromSortedSlice(vals []c.Comparable) *AvlTree {
+	t := &AvlTree{}
+	t.build(vals)
+	return t
+}
+
+// Rebuild replaces the contents of the tree with the values of a slice sorted
+// in ascending order, as for BuildFromSortedSlice, keeping its ordering, codec
+// and cursor mode.  Views of the tree follow the new contents.
+func (t *AvlTreeOf[T]) Rebuild(vals []T) {
+	t.build(vals)
+}
+
+// build replaces the contents of the tree with the sorted values in vals.
+func (t *AvlTreeOf[T]) build(vals []T) {
+	nodes := make([]*avlNode[T], 0, len(vals))
+	for _, v := range vals {
+		if k := len(nodes); k > 0 {
+			r := t.compare(nodes[k-1].data, v)
+			if r > 0 {
+				panic("BuildFromSorted() input out of order")
+			}
+			if r == 0 {
+				nodes[k-1].data = v
+				continue
+			}
+		}
+		nodes = append(nodes, &avlNode[T]{data: v})
+	}
+	for i, n := range nodes {
+		if i > 0 {
+			n.prv = nodes[i-1]
+			nodes[i-1].nxt = n
+		}
+	}
+	t.reset()
+	t.root = t.buildBalanced(nodes, nil)
+	t.size = uint(len(nodes))
+	if len(nodes) > 0 {
+		t.head, t.tail = nodes[0], nodes[len(nodes)-1]
+	}
+}
+
+// buildBalanced links nodes into a perfectly balanced subtree under p,
+// returning its root, and updates the size and any augmented value of each
+// node.  The height of a subtree of n nodes built this way is bits.Len(n),
+// which gives the balance factors directly.
+func (t *AvlTreeOf[T]) buildBalanced(nodes []*avlNode[T], p *avlNode[T]) *avlNode[T] {
+	if len(nodes) == 0 {
+		return nil
+	}
+	mid := len(nodes) / 2
+	n := nodes[mid]
+	n.p = p
+	n.l = t.buildBalanced(nodes[:mid], n)
+	n.r = t.buildBalanced(nodes[mid+1:], n)
+	t.update(n)
+	n.balance = int8(bits.Len(uint(len(nodes)-mid-1)) - bits.Len(uint(mid)))
+	return n
+}
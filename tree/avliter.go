@@ -0,0 +1,67 @@
+package tree
+
+import (
+	"iter"
+
+	c "github.com/dtromb/collections"
+)
+
+// The sequences in this file follow the threads of the tree directly.  Each
+// step reads the link to the following node before yielding, so the loop body
+// may delete the value it was given.
+
+// All returns a sequence of the values in the tree, in order.
+func (t *AvlTreeOf[T]) All() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		t.ascend(t.head, nil, yield)
+	}
+}
+
+// Backward returns a sequence of the values in the tree, in reverse order.
+func (t *AvlTreeOf[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := t.tail; n != nil; {
+			prv := n.prv
+			if !yield(n.data) {
+				return
+			}
+			n = prv
+		}
+	}
+}
+
+// Range returns a sequence of the values v in the tree with lo <= v <= hi,
+// in order.
+func (t *AvlTreeOf[T]) Range(lo, hi T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		n, _ := t.lookupNode(c.GTE, lo)
+		t.ascend(n, func(v T) bool { return t.compare(v, hi) <= 0 }, yield)
+	}
+}
+
+// From returns a sequence of the values in the tree in order, starting with
+// the value that would have been returned by an equivalent Lookup() call.  It
+// yields exactly what Next() would from the cursor returned by GetCursor().
+func (t *AvlTreeOf[T]) From(lt c.LookupType, x T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		n, _ := t.lookupNode(lt, x)
+		if n == nil && !ascending(lt) {
+			n = t.head
+		}
+		t.ascend(n, nil, yield)
+	}
+}
+
+// ascend yields the values from n onward while they satisfy within (if given).
+func (t *AvlTreeOf[T]) ascend(n *avlNode[T], within func(T) bool, yield func(T) bool) {
+	for n != nil {
+		nxt := n.nxt
+		if within != nil && !within(n.data) {
+			return
+		}
+		if !yield(n.data) {
+			return
+		}
+		n = nxt
+	}
+}
@@ -0,0 +1,368 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// The operations in this file work on detached subtrees identified by their
+// root node and height, using the receiver only as scratch space for the
+// rebalancing code (which updates t.root when rotating at the top).  Threads
+// are not maintained by the node-level functions; the exported operations
+// repair them afterwards.
+
+// height returns the height of the subtree rooted at n in O(ln(n)) time.
+func height[T any](n *avlNode[T]) int {
+	var h int
+	for n != nil {
+		h++
+		if n.balance < 0 {
+			n = n.l
+		} else {
+			n = n.r
+		}
+	}
+	return h
+}
+
+// childHeights returns the heights of the subtrees of n, given its height.
+func childHeights[T any](n *avlNode[T], h int) (lh, rh int) {
+	lh, rh = h-1, h-1
+	if n.balance > 0 {
+		lh--
+	} else if n.balance < 0 {
+		rh--
+	}
+	return lh, rh
+}
+
+// expose detaches and returns the children of n along with their heights.
+func expose[T any](n *avlNode[T], h int) (l *avlNode[T], lh int, r *avlNode[T], rh int) {
+	lh, rh = childHeights(n, h)
+	l, r = n.l, n.r
+	if l != nil {
+		l.p = nil
+	}
+	if r != nil {
+		r.p = nil
+	}
+	return l, lh, r, rh
+}
+
+// join returns a balanced tree holding l, then k, then r, where every value
+// in l orders before k.data and every value in r after it.  It runs in
+// O(|lh-rh|+1) time.
+func (t *AvlTreeOf[T]) join(l *avlNode[T], lh int, k *avlNode[T], r *avlNode[T], rh int) (*avlNode[T], int) {
+	if l != nil {
+		l.p = nil
+	}
+	if r != nil {
+		r.p = nil
+	}
+	if lh > rh+1 {
+		// Descend the right spine of l to the first subtree no more than one
+		// level taller than r, and hang k there with that subtree and r below.
+		var p *avlNode[T]
+		cn, h := l, lh
+		for h > rh+1 {
+			if cn.balance < 0 {
+				h -= 2
+			} else {
+				h--
+			}
+			p, cn = cn, cn.r
+		}
+		k.l, k.r, k.p = cn, r, p
+		if cn != nil {
+			cn.p = k
+		}
+		if r != nil {
+			r.p = k
+		}
+		p.r = k
+		k.balance = int8(rh - h)
+		for an := k; an != nil; an = an.p {
+			t.update(an)
+		}
+		p.balance++
+		t.root = l
+		if t.growAtNode(p) {
+			lh++
+		}
+		return t.root, lh
+	}
+	if rh > lh+1 {
+		var p *avlNode[T]
+		cn, h := r, rh
+		for h > lh+1 {
+			if cn.balance > 0 {
+				h -= 2
+			} else {
+				h--
+			}
+			p, cn = cn, cn.l
+		}
+		k.l, k.r, k.p = l, cn, p
+		if cn != nil {
+			cn.p = k
+		}
+		if l != nil {
+			l.p = k
+		}
+		p.l = k
+		k.balance = int8(h - lh)
+		for an := k; an != nil; an = an.p {
+			t.update(an)
+		}
+		p.balance--
+		t.root = r
+		if t.growAtNode(p) {
+			rh++
+		}
+		return t.root, rh
+	}
+	k.l, k.r, k.p = l, r, nil
+	if l != nil {
+		l.p = k
+	}
+	if r != nil {
+		r.p = k
+	}
+	k.balance = int8(rh - lh)
+	t.update(k)
+	t.root = k
+	if lh > rh {
+		return k, lh + 1
+	}
+	return k, rh + 1
+}
+
+// join2 returns a balanced tree holding l followed by r.
+func (t *AvlTreeOf[T]) join2(l *avlNode[T], lh int, r *avlNode[T], rh int) (*avlNode[T], int) {
+	if l == nil {
+		return r, rh
+	}
+	l, lh, k := t.splitLast(l, lh)
+	return t.join(l, lh, k, r, rh)
+}
+
+// splitLast detaches the last node of the subtree n, returning it along with
+// the remaining tree.
+func (t *AvlTreeOf[T]) splitLast(n *avlNode[T], h int) (*avlNode[T], int, *avlNode[T]) {
+	l, lh, r, rh := expose(n, h)
+	if r == nil {
+		return l, lh, n
+	}
+	r, rh, k := t.splitLast(r, rh)
+	l, lh = t.join(l, lh, n, r, rh)
+	return l, lh, k
+}
+
+// split divides the subtree n into the values ordered before and after pivot.
+// A node equal to pivot is detached and returned separately.
+func (t *AvlTreeOf[T]) split(n *avlNode[T], h int, pivot T) (l *avlNode[T], lh int, eq *avlNode[T], r *avlNode[T], rh int) {
+	if n == nil {
+		return nil, 0, nil, nil, 0
+	}
+	nl, nlh, nr, nrh := expose(n, h)
+	cmp := t.compare(pivot, n.data)
+	if cmp == 0 {
+		return nl, nlh, n, nr, nrh
+	}
+	if cmp < 0 {
+		l, lh, eq, r, rh = t.split(nl, nlh, pivot)
+		r, rh = t.join(r, rh, n, nr, nrh)
+		return l, lh, eq, r, rh
+	}
+	l, lh, eq, r, rh = t.split(nr, nrh, pivot)
+	l, lh = t.join(nl, nlh, n, l, lh)
+	return l, lh, eq, r, rh
+}
+
+// rethread rebuilds the size, head, tail and thread links of the tree from
+// its structure in O(n) time.
+func (t *AvlTreeOf[T]) rethread() {
+	t.size = t.root.count()
+	t.mods++
+	t.head, t.tail = nil, nil
+	var last *avlNode[T]
+	var visit func(n *avlNode[T])
+	visit = func(n *avlNode[T]) {
+		if n.l != nil {
+			visit(n.l)
+		}
+		n.prv = last
+		if last != nil {
+			last.nxt = n
+		} else {
+			t.head = n
+		}
+		last = n
+		if n.r != nil {
+			visit(n.r)
+		}
+	}
+	if t.root != nil {
+		t.root.p = nil
+		visit(t.root)
+		last.nxt = nil
+	}
+	t.tail = last
+}
+
+// Split divides the tree around pivot in O(ln(n)) time, returning a tree of
+// the values ordered before pivot and a tree of those equal to or after it.
+// The receiver is left empty.
+func (t *AvlTreeOf[T]) Split(pivot T) (left, right *AvlTreeOf[T]) {
+	left, right = t.empty(), t.empty()
+	if t.root == nil {
+		return left, right
+	}
+	head, tail := t.head, t.tail
+	bn, _ := t.lookupNode(c.GTE, pivot)
+	l, _, eq, r, rh := t.split(t.root, height(t.root), pivot)
+	if eq != nil {
+		r, _ = t.join(nil, 0, eq, r, rh)
+	}
+	left.root, right.root = l, r
+	left.size, right.size = l.count(), r.count()
+	if l != nil {
+		left.head = head
+		if bn != nil {
+			left.tail = bn.prv
+		} else {
+			left.tail = tail
+		}
+		left.tail.nxt = nil
+	}
+	if r != nil {
+		right.head = bn
+		right.tail = tail
+		bn.prv = nil
+	}
+	t.reset()
+	return left, right
+}
+
+// Join replaces the contents of the receiver with the concatenation of left
+// and right, in O(ln(n)) time.  Every value in left must order before every
+// value in right.  Both arguments are left empty, and either may be the
+// receiver itself.
+func (t *AvlTreeOf[T]) Join(left, right *AvlTreeOf[T]) {
+	if left.size > 0 && right.size > 0 &&
+		t.compare(left.tail.data, right.head.data) >= 0 {
+		panic("Join() arguments overlap")
+	}
+	nt := *t.empty()
+	switch {
+	case right.size == 0:
+		nt = *left
+	case left.size == 0:
+		nt = *right
+	default:
+		k := right.head
+		right.Delete(k.data)
+		nt.join(left.root, height(left.root), k, right.root, height(right.root))
+		nt.size = left.size + right.size + 1
+		nt.head = left.head
+		left.tail.nxt = k
+		k.prv = left.tail
+		k.nxt = right.head
+		if right.head != nil {
+			right.head.prv = k
+			nt.tail = right.tail
+		} else {
+			nt.tail = k
+		}
+	}
+	left.reset()
+	right.reset()
+	t.root, t.size, t.head, t.tail = nt.root, nt.size, nt.head, nt.tail
+	t.mods++
+}
+
+// Clone returns a copy of the tree with the same shape, in O(n) time and
+// without comparing any values.
+func (t *AvlTreeOf[T]) Clone() *AvlTreeOf[T] {
+	var clone func(n, p *avlNode[T]) *avlNode[T]
+	clone = func(n, p *avlNode[T]) *avlNode[T] {
+		if n == nil {
+			return nil
+		}
+		cn := &avlNode[T]{
+			data:    n.data,
+			balance: n.balance,
+			size:    n.size,
+			p:       p,
+		}
+		cn.l = clone(n.l, cn)
+		cn.r = clone(n.r, cn)
+		return cn
+	}
+	nt := t.empty()
+	nt.root = clone(t.root, nil)
+	nt.rethread()
+	return nt
+}
+
+// UnionWith adds every value of o to the receiver, keeping the receiver's
+// value where both hold an equal one.  o is left empty.  Using join-based
+// set algebra it performs O(m ln(n/m + 1)) comparisons for trees of sizes
+// m <= n; relinking the threads of the result then takes O(n + m) time.
+func (t *AvlTreeOf[T]) UnionWith(o *AvlTreeOf[T]) {
+	t.root, _ = t.union(t.root, height(t.root), o.root, height(o.root))
+	o.reset()
+	t.rethread()
+}
+
+func (t *AvlTreeOf[T]) union(a *avlNode[T], ah int, b *avlNode[T], bh int) (*avlNode[T], int) {
+	if a == nil {
+		return b, bh
+	}
+	if b == nil {
+		return a, ah
+	}
+	al, alh, ar, arh := expose(a, ah)
+	bl, blh, _, br, brh := t.split(b, bh, a.data)
+	l, lh := t.union(al, alh, bl, blh)
+	r, rh := t.union(ar, arh, br, brh)
+	return t.join(l, lh, a, r, rh)
+}
+
+// IntersectWith removes every value from the receiver that does not have an
+// equal value in o.  o is left empty.  The cost is as for UnionWith.
+func (t *AvlTreeOf[T]) IntersectWith(o *AvlTreeOf[T]) {
+	t.root, _ = t.intersect(t.root, height(t.root), o.root, height(o.root))
+	o.reset()
+	t.rethread()
+}
+
+func (t *AvlTreeOf[T]) intersect(a *avlNode[T], ah int, b *avlNode[T], bh int) (*avlNode[T], int) {
+	if a == nil || b == nil {
+		return nil, 0
+	}
+	al, alh, ar, arh := expose(a, ah)
+	bl, blh, eq, br, brh := t.split(b, bh, a.data)
+	l, lh := t.intersect(al, alh, bl, blh)
+	r, rh := t.intersect(ar, arh, br, brh)
+	if eq != nil {
+		return t.join(l, lh, a, r, rh)
+	}
+	return t.join2(l, lh, r, rh)
+}
+
+// DifferenceWith removes every value from the receiver that has an equal
+// value in o.  o is left empty.  The cost is as for UnionWith.
+func (t *AvlTreeOf[T]) DifferenceWith(o *AvlTreeOf[T]) {
+	t.root, _ = t.difference(t.root, height(t.root), o.root, height(o.root))
+	o.reset()
+	t.rethread()
+}
+
+func (t *AvlTreeOf[T]) difference(a *avlNode[T], ah int, b *avlNode[T], bh int) (*avlNode[T], int) {
+	if a == nil || b == nil {
+		return a, ah
+	}
+	bl, blh, br, brh := expose(b, bh)
+	al, alh, _, ar, arh := t.split(a, ah, b.data)
+	l, lh := t.difference(al, alh, bl, blh)
+	r, rh := t.difference(ar, arh, br, brh)
+	return t.join2(l, lh, r, rh)
+}
@@ -0,0 +1,326 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// RangedTreeOf is implemented by trees that can present a bounded range of
+// their values as a live view.  A view reflects later changes to the tree,
+// and changes made through it are made to the tree.
+type RangedTreeOf[T any] interface {
+	TreeOf[T]
+	SubTree(lo, hi T, loInclusive, hiInclusive bool) RangedTreeOf[T]
+	HeadTree(hi T, inclusive bool) RangedTreeOf[T]
+	TailTree(lo T, inclusive bool) RangedTreeOf[T]
+	InRange(x T) bool
+}
+
+// RangedTree is a RangedTreeOf Comparable values.
+type RangedTree = RangedTreeOf[c.Comparable]
+
+// avlView is a view of the values of an AvlTreeOf between optional bounds.
+type avlView[T any] struct {
+	tree         *AvlTreeOf[T]
+	lo, hi       T
+	hasLo, hasHi bool
+	loInc, hiInc bool
+}
+
+type avlViewCursor[T any] struct {
+	view *avlView[T]
+	cur  *treeCursor[T]
+}
+
+// SubTree returns a view of the values of the tree between lo and hi, each
+// bound being included in the range if the matching flag is set.  Inserting
+// a value outside the range through the view panics.
+func (t *AvlTreeOf[T]) SubTree(lo, hi T, loInclusive, hiInclusive bool) RangedTreeOf[T] {
+	return &avlView[T]{tree: t, lo: lo, hi: hi, hasLo: true, hasHi: true,
+		loInc: loInclusive, hiInc: hiInclusive}
+}
+
+// HeadTree returns a view of the values of the tree before hi (or equal to
+// it, if inclusive is set).
+func (t *AvlTreeOf[T]) HeadTree(hi T, inclusive bool) RangedTreeOf[T] {
+	return &avlView[T]{tree: t, hi: hi, hasHi: true, hiInc: inclusive}
+}
+
+// TailTree returns a view of the values of the tree after lo (or equal to
+// it, if inclusive is set).
+func (t *AvlTreeOf[T]) TailTree(lo T, inclusive bool) RangedTreeOf[T] {
+	return &avlView[T]{tree: t, lo: lo, hasLo: true, loInc: inclusive}
+}
+
+// InRange reports true for every value, the tree being unbounded.
+func (t *AvlTreeOf[T]) InRange(x T) bool {
+	return true
+}
+
+// below checks whether x orders before the range of the view.
+func (v *avlView[T]) below(x T) bool {
+	if !v.hasLo {
+		return false
+	}
+	r := v.tree.compare(x, v.lo)
+	return r < 0 || (r == 0 && !v.loInc)
+}
+
+// above checks whether x orders after the range of the view.
+func (v *avlView[T]) above(x T) bool {
+	if !v.hasHi {
+		return false
+	}
+	r := v.tree.compare(x, v.hi)
+	return r > 0 || (r == 0 && !v.hiInc)
+}
+
+// InRange checks whether x lies within the bounds of the view, and so may be
+// inserted through it.
+func (v *avlView[T]) InRange(x T) bool {
+	return !v.below(x) && !v.above(x)
+}
+
+// firstNode returns the first node in range, or nil if there is none.
+func (v *avlView[T]) firstNode() *avlNode[T] {
+	n := v.tree.head
+	if v.hasLo {
+		lt := c.GT
+		if v.loInc {
+			lt = c.GTE
+		}
+		n, _ = v.tree.lookupNode(lt, v.lo)
+	}
+	if n == nil || v.above(n.data) {
+		return nil
+	}
+	return n
+}
+
+// lastNode returns the last node in range, or nil if there is none.
+func (v *avlView[T]) lastNode() *avlNode[T] {
+	n := v.tree.tail
+	if v.hasHi {
+		lt := c.LT
+		if v.hiInc {
+			lt = c.LTE
+		}
+		n, _ = v.tree.lookupNode(lt, v.hi)
+	}
+	if n == nil || v.below(n.data) {
+		return nil
+	}
+	return n
+}
+
+// lookupNode is AvlTreeOf.lookupNode clipped to the range of the view.
+func (v *avlView[T]) lookupNode(lt c.LookupType, data T) (*avlNode[T], bool) {
+	n, exact := v.tree.lookupNode(lt, data)
+	exact = exact && v.InRange(data)
+	if n == nil {
+		return nil, exact
+	}
+	if ascending(lt) && v.below(n.data) {
+		return v.firstNode(), exact
+	}
+	if !ascending(lt) && v.above(n.data) {
+		return v.lastNode(), exact
+	}
+	if !v.InRange(n.data) {
+		return nil, exact
+	}
+	return n, exact
+}
+
+// Size returns the number of values in range.  O(ln(n)).
+func (v *avlView[T]) Size() uint {
+	var lc, hc uint
+	if v.hasLo {
+		r, has := v.tree.Rank(v.lo)
+		if has && !v.loInc {
+			r++
+		}
+		lc = r
+	}
+	hc = v.tree.size
+	if v.hasHi {
+		r, has := v.tree.Rank(v.hi)
+		if has && v.hiInc {
+			r++
+		}
+		hc = r
+	}
+	if hc < lc {
+		return 0
+	}
+	return hc - lc
+}
+
+func (v *avlView[T]) Has(data T) bool {
+	return v.InRange(data) && v.tree.Has(data)
+}
+
+// Lookup finds a value in range according to the given parameters.
+func (v *avlView[T]) Lookup(lt c.LookupType, data T) (T, bool) {
+	var zero T
+	n, exact := v.lookupNode(lt, data)
+	if n == nil || (lt == c.EQ && !exact) {
+		return zero, exact
+	}
+	return n.data, exact
+}
+
+// Insert adds or replaces a value in the tree, as for AvlTreeOf.Insert.  It
+// panics if the value is outside the range of the view.
+func (v *avlView[T]) Insert(data T) T {
+	if !v.InRange(data) {
+		panic("Insert() value outside the range of the view")
+	}
+	return v.tree.Insert(data)
+}
+
+// Delete removes a value in range from the tree, as for AvlTreeOf.Delete.
+func (v *avlView[T]) Delete(data T) (T, bool) {
+	var zero T
+	if !v.InRange(data) {
+		return zero, false
+	}
+	return v.tree.Delete(data)
+}
+
+// First opens a cursor positioned before the first value in range.
+func (v *avlView[T]) First() c.CursorOf[T] {
+	n := v.firstNode()
+	return &avlViewCursor[T]{view: v, cur: v.tree.cursor(n, n == nil)}
+}
+
+// Last opens a cursor positioned after the last value in range.
+func (v *avlView[T]) Last() c.CursorOf[T] {
+	var nxt *avlNode[T]
+	if n := v.lastNode(); n != nil {
+		nxt = n.nxt
+	}
+	return &avlViewCursor[T]{view: v, cur: v.tree.cursor(nxt, nxt == nil)}
+}
+
+// GetCursor opens a cursor as for AvlTreeOf.GetCursor, which cannot move
+// outside the range of the view.
+func (v *avlView[T]) GetCursor(lt c.LookupType, data T) (c.CursorOf[T], bool) {
+	n, exact := v.lookupNode(lt, data)
+	if n == nil {
+		if ascending(lt) {
+			return v.Last(), exact
+		}
+		return v.First(), exact
+	}
+	return &avlViewCursor[T]{view: v, cur: v.tree.cursor(n, false)}, exact
+}
+
+// SubTree returns a view of the values in both the range of the receiver and
+// the given one.
+func (v *avlView[T]) SubTree(lo, hi T, loInclusive, hiInclusive bool) RangedTreeOf[T] {
+	nv := *v
+	nv.narrowLo(lo, loInclusive)
+	nv.narrowHi(hi, hiInclusive)
+	return &nv
+}
+
+func (v *avlView[T]) HeadTree(hi T, inclusive bool) RangedTreeOf[T] {
+	nv := *v
+	nv.narrowHi(hi, inclusive)
+	return &nv
+}
+
+func (v *avlView[T]) TailTree(lo T, inclusive bool) RangedTreeOf[T] {
+	nv := *v
+	nv.narrowLo(lo, inclusive)
+	return &nv
+}
+
+// narrowLo raises the lower bound of the view to lo, if that is higher.
+func (v *avlView[T]) narrowLo(lo T, inclusive bool) {
+	if v.hasLo {
+		r := v.tree.compare(lo, v.lo)
+		if r < 0 || (r == 0 && inclusive) {
+			return
+		}
+	}
+	v.lo, v.hasLo, v.loInc = lo, true, inclusive
+}
+
+// narrowHi lowers the upper bound of the view to hi, if that is lower.
+func (v *avlView[T]) narrowHi(hi T, inclusive bool) {
+	if v.hasHi {
+		r := v.tree.compare(hi, v.hi)
+		if r > 0 || (r == 0 && inclusive) {
+			return
+		}
+	}
+	v.hi, v.hasHi, v.hiInc = hi, true, inclusive
+}
+
+// peekNext returns the node the underlying cursor would return from Next().
+func (vc *avlViewCursor[T]) peekNext() *avlNode[T] {
+	if vc.cur.nextNode == nil && !vc.cur.end {
+		return vc.cur.tree.head
+	}
+	return vc.cur.nextNode
+}
+
+// peekPrev returns the node the underlying cursor would return from Prev().
+func (vc *avlViewCursor[T]) peekPrev() *avlNode[T] {
+	if vc.cur.nextNode == nil {
+		if vc.cur.end {
+			return vc.cur.tree.tail
+		}
+		return nil
+	}
+	return vc.cur.nextNode.prv
+}
+
+// HasNext checks for the availability of a next data value in range.
+func (vc *avlViewCursor[T]) HasNext() bool {
+	if !vc.cur.HasNext() {
+		return false
+	}
+	n := vc.peekNext()
+	return n != nil && vc.view.InRange(n.data)
+}
+
+// HasPrev checks for the availability of a previous data value in range.
+func (vc *avlViewCursor[T]) HasPrev() bool {
+	if !vc.cur.HasPrev() {
+		return false
+	}
+	n := vc.peekPrev()
+	return n != nil && vc.view.InRange(n.data)
+}
+
+// Next retrieves the next value from the cursor, if it is in range.
+func (vc *avlViewCursor[T]) Next() T {
+	var zero T
+	if !vc.HasNext() {
+		vc.cur.last = nil
+		return zero
+	}
+	return vc.cur.Next()
+}
+
+// Prev retrieves the previous value from the cursor, if it is in range.
+func (vc *avlViewCursor[T]) Prev() T {
+	var zero T
+	if !vc.HasPrev() {
+		vc.cur.last = nil
+		return zero
+	}
+	return vc.cur.Prev()
+}
+
+// Remove deletes the value most recently returned, as for the cursor of
+// AvlTreeOf.
+func (vc *avlViewCursor[T]) Remove() {
+	vc.cur.Remove()
+}
+
+// Set replaces the value most recently returned, as for the cursor of
+// AvlTreeOf.
+func (vc *avlViewCursor[T]) Set(v T) {
+	vc.cur.Set(v)
+}
@@ -0,0 +1,166 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// bsTree is the threaded binary search tree shared by the self-adjusting
+// trees (SplayTree and Treap), which differ only in how they restructure it.
+// As in AvlTree, each node is threaded to its successor and predecessor, so
+// that cursors iterate in O(1) time and survive rotations.
+type bsTree struct {
+	root *bsNode
+	head *bsNode
+	tail *bsNode
+	size uint
+}
+
+type bsNode struct {
+	data              c.Comparable
+	prio              uint64
+	l, r, p, nxt, prv *bsNode
+}
+
+func (n *bsNode) dataRef() *c.Comparable { return &n.data }
+func (n *bsNode) succ() *bsNode          { return n.nxt }
+func (n *bsNode) pred() *bsNode          { return n.prv }
+
+func (t *bsTree) ends() (*bsNode, *bsNode) {
+	return t.head, t.tail
+}
+
+// locate descends from the root towards x, returning the node holding x, or
+// else the last node visited (which holds the floor or ceiling of x), along
+// with the comparison of x to its value.  It returns nil for an empty tree.
+func (t *bsTree) locate(x c.Comparable) (*bsNode, int8) {
+	cn := t.root
+	for cn != nil {
+		r := x.CompareTo(cn.data)
+		switch {
+		case r == 0:
+			return cn, 0
+		case r < 0 && cn.l != nil:
+			cn = cn.l
+		case r > 0 && cn.r != nil:
+			cn = cn.r
+		default:
+			return cn, r
+		}
+	}
+	return nil, 0
+}
+
+// neighbor returns the node a lookup of the given type finds, given the
+// result of locate.
+func neighbor(lt c.LookupType, n *bsNode, r int8) *bsNode {
+	if n == nil {
+		return nil
+	}
+	switch {
+	case r == 0 && lt == c.LT:
+		return n.prv
+	case r == 0 && lt == c.GT:
+		return n.nxt
+	case r == 0:
+		return n
+	case r < 0 && ascending(lt):
+		return n
+	case r < 0:
+		return n.prv
+	case ascending(lt):
+		return n.nxt
+	}
+	return n
+}
+
+// attach links nn into the tree below n (found by locate, with r the
+// comparison of nn.data to n.data), and into the threads.
+func (t *bsTree) attach(nn, n *bsNode, r int8) {
+	t.size++
+	nn.p = n
+	if n == nil {
+		t.root, t.head, t.tail = nn, nn, nn
+		return
+	}
+	if r < 0 {
+		n.l = nn
+		nn.nxt, nn.prv = n, n.prv
+		if n.prv != nil {
+			n.prv.nxt = nn
+		} else {
+			t.head = nn
+		}
+		n.prv = nn
+	} else {
+		n.r = nn
+		nn.prv, nn.nxt = n, n.nxt
+		if n.nxt != nil {
+			n.nxt.prv = nn
+		} else {
+			t.tail = nn
+		}
+		n.nxt = nn
+	}
+}
+
+// unthread removes n from the threads once it has been unlinked from the
+// tree.
+func (t *bsTree) unthread(n *bsNode) {
+	if n.prv != nil {
+		n.prv.nxt = n.nxt
+	} else {
+		t.head = n.nxt
+	}
+	if n.nxt != nil {
+		n.nxt.prv = n.prv
+	} else {
+		t.tail = n.prv
+	}
+	n.nxt, n.prv, n.p, n.l, n.r = nil, nil, nil, nil, nil
+	t.size--
+}
+
+// replace puts n in the place of o below o's parent.
+func (t *bsTree) replace(o, n *bsNode) {
+	p := o.p
+	if n != nil {
+		n.p = p
+	}
+	switch {
+	case p == nil:
+		t.root = n
+	case p.l == o:
+		p.l = n
+	default:
+		p.r = n
+	}
+}
+
+// rotateUp rotates x above its parent.
+func (t *bsTree) rotateUp(x *bsNode) {
+	p := x.p
+	t.replace(p, x)
+	if p.l == x {
+		p.l = x.r
+		if x.r != nil {
+			x.r.p = p
+		}
+		x.r = p
+	} else {
+		p.r = x.l
+		if x.l != nil {
+			x.l.p = p
+		}
+		x.l = p
+	}
+	p.p = x
+}
+
+// Size returns the number of elements in the tree.
+func (t *bsTree) Size() uint {
+	return t.size
+}
+
+// cursor opens a cursor at n; del unlinks a node from the tree in the manner
+// of the tree implementation.
+func (t *bsTree) cursor(n *bsNode, end bool, del func(n *bsNode)) c.Cursor {
+	return newThreadCursor(t, n, end, del)
+}
@@ -0,0 +1,118 @@
+package tree
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// checkBsTree panics unless the tree is ordered, each node is linked to its
+// parent, the threads follow the in-order sequence and the head, tail and
+// size agree with the nodes.
+func checkBsTree(t *bsTree) {
+	if t.root != nil && t.root.p != nil {
+		panic("root has a parent")
+	}
+	var order []*bsNode
+	var walk func(n *bsNode)
+	walk = func(n *bsNode) {
+		for _, ch := range []*bsNode{n.l, n.r} {
+			if ch != nil && ch.p != n {
+				panic("child not linked to parent")
+			}
+		}
+		if n.l != nil {
+			walk(n.l)
+		}
+		order = append(order, n)
+		if n.r != nil {
+			walk(n.r)
+		}
+	}
+	if t.root != nil {
+		walk(t.root)
+	}
+	var prev *bsNode
+	for _, n := range order {
+		if prev != nil && prev.data.CompareTo(n.data) >= 0 {
+			panic("tree out of order")
+		}
+		if n.prv != prev || prev != nil && prev.nxt != n {
+			panic("threads do not match")
+		}
+		prev = n
+	}
+	if uint(len(order)) != t.size || prev != t.tail || len(order) > 0 && order[0] != t.head {
+		panic("head, tail or size does not match")
+	}
+	if prev != nil && prev.nxt != nil {
+		panic("tail has a successor")
+	}
+}
+
+// checkTreap panics unless the treap is a valid bsTree in heap order by
+// priority.
+func checkTreap(t *Treap) {
+	checkBsTree(&t.bsTree)
+	for n := t.head; n != nil; n = n.nxt {
+		if n.p != nil && n.p.prio < n.prio {
+			panic("treap out of heap order")
+		}
+	}
+}
+
+func TestSplayTreeAccess(t *testing.T) {
+	st := NewSplayTree()
+	for _, k := range rand.New(rand.NewPCG(1, 1)).Perm(500) {
+		st.Insert(ComparableInt(k))
+		if st.root.data != ComparableInt(k) {
+			t.Fatalf("inserted %d is not at the root", k)
+		}
+	}
+	for _, k := range []int{0, 499, 250, 17} {
+		st.Has(ComparableInt(k))
+		if st.root.data != ComparableInt(k) {
+			t.Errorf("accessed %d is not at the root", k)
+		}
+	}
+	// A miss splays the last node visited, which is a neighbour of the key.
+	st.Delete(ComparableInt(100))
+	st.Has(ComparableInt(100))
+	if r := st.root.data; r != ComparableInt(99) && r != ComparableInt(101) {
+		t.Errorf("missed lookup left %v at the root", r)
+	}
+	checkBsTree(&st.bsTree)
+}
+
+// treapShape returns the priorities of the nodes of a treap in order.
+func treapShape(t *Treap) []uint64 {
+	var r []uint64
+	for n := t.head; n != nil; n = n.nxt {
+		r = append(r, n.prio)
+	}
+	return r
+}
+
+func TestTreapSource(t *testing.T) {
+	build := func(seed uint64) *Treap {
+		tr := NewTreap(rand.NewPCG(seed, 0))
+		rnd := rand.New(rand.NewPCG(7, 7))
+		for i := 0; i < 2000; i++ {
+			x := ComparableInt(rnd.IntN(1000))
+			if rnd.IntN(3) == 0 {
+				tr.Delete(x)
+			} else {
+				tr.Insert(x)
+			}
+		}
+		checkTreap(tr)
+		return tr
+	}
+	a, b, other := build(1), build(1), build(2)
+	if fmt.Sprint(treapShape(a)) != fmt.Sprint(treapShape(b)) {
+		t.Error("treaps built from equal sources have different shapes")
+	}
+	if fmt.Sprint(treapShape(a)) == fmt.Sprint(treapShape(other)) {
+		t.Error("treaps built from different sources have the same shape")
+	}
+}
@@ -0,0 +1,460 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// DefaultBTreeOrder is the fanout used by a zero BTree or NewTree(BTREE).
+const DefaultBTreeOrder = 64
+
+// BTree is an implementation of a B+ tree.  Values are stored only in the
+// leaves, which are linked to their siblings, so iteration is O(1) per step.
+// Other operations are O(ln(n)), with far fewer allocations and pointer
+// dereferences per element than the binary trees for large collections.
+type BTree struct {
+	size  uint
+	order int
+	root  *btNode
+	head  *btNode
+	tail  *btNode
+}
+
+type btCursor struct {
+	tree *BTree
+	leaf *btNode
+	idx  int
+	end  bool
+}
+
+// btNode is a leaf (children == nil) or an inner node.  For an inner node,
+// keys[i] separates children[i] (all values < keys[i]) from children[i+1]
+// (all values >= keys[i]).  Only leaves use the sibling links.
+type btNode struct {
+	keys     []c.Comparable
+	children []*btNode
+	nxt, prv *btNode
+}
+
+// NewBTree creates an empty B+ tree whose nodes hold at most order values
+// (leaves) or children (inner nodes).  The order must be at least 3.
+func NewBTree(order int) *BTree {
+	if order < 3 {
+		panic("B+ tree order must be at least 3")
+	}
+	return &BTree{order: order}
+}
+
+func (n *btNode) leaf() bool {
+	return n.children == nil
+}
+
+// search returns the number of keys in n ordered less than (or, if upper is
+// true, less than or equal to) data.
+func (n *btNode) search(data c.Comparable, upper bool) int {
+	lo, hi := 0, len(n.keys)
+	for lo < hi {
+		m := int(uint(lo+hi) >> 1)
+		r := data.CompareTo(n.keys[m])
+		if r > 0 || (upper && r == 0) {
+			lo = m + 1
+		} else {
+			hi = m
+		}
+	}
+	return lo
+}
+
+func (t *BTree) init() {
+	if t.order == 0 {
+		t.order = DefaultBTreeOrder
+	}
+	t.root = &btNode{keys: make([]c.Comparable, 0, t.order+1)}
+	t.head = t.root
+	t.tail = t.root
+}
+
+func (t *BTree) minKeys() int {
+	return t.order / 2
+}
+
+func (t *BTree) minChildren() int {
+	return (t.order + 1) / 2
+}
+
+// Size returns the number of elements in the tree.
+func (t *BTree) Size() uint {
+	return t.size
+}
+
+func (t *BTree) Has(data c.Comparable) bool {
+	_, has := t.Lookup(c.LTE, data)
+	return has
+}
+
+// findLeaf returns the leaf whose range covers data.
+func (t *BTree) findLeaf(data c.Comparable) *btNode {
+	cn := t.root
+	for !cn.leaf() {
+		cn = cn.children[cn.search(data, true)]
+	}
+	return cn
+}
+
+// lookupPos returns the position of the value found by a lookup, or a nil
+// leaf if there is none.
+func (t *BTree) lookupPos(lt c.LookupType, data c.Comparable) (*btNode, int, bool) {
+	if t.size == 0 {
+		return nil, 0, false
+	}
+	leaf := t.findLeaf(data)
+	i := leaf.search(data, false)
+	exact := i < len(leaf.keys) && data.CompareTo(leaf.keys[i]) == 0
+	if exact {
+		switch lt {
+		case c.GT:
+			i++
+		case c.LT:
+			i--
+		default:
+			return leaf, i, true
+		}
+	} else if !ascending(lt) {
+		i--
+	}
+	if i == len(leaf.keys) {
+		return leaf.nxt, 0, exact
+	}
+	if i < 0 {
+		if leaf.prv == nil {
+			return nil, 0, exact
+		}
+		return leaf.prv, len(leaf.prv.keys) - 1, exact
+	}
+	return leaf, i, exact
+}
+
+// Lookup finds a value in the tree according to the given parameters.
+func (t *BTree) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	leaf, i, exact := t.lookupPos(lt, data)
+	if leaf == nil || (lt == c.EQ && !exact) {
+		return nil, exact
+	}
+	return leaf.keys[i], exact
+}
+
+// Insert adds or replaces a value in the tree.  If there is already an equal value,
+// it is replaced and the old value returned.  Otherwise, nil is returned.
+func (t *BTree) Insert(data c.Comparable) c.Comparable {
+	if t.root == nil {
+		t.init()
+	}
+	old, split, sep := t.insert(t.root, data)
+	if split != nil {
+		nr := &btNode{
+			keys:     make([]c.Comparable, 1, t.order),
+			children: make([]*btNode, 2, t.order+1),
+		}
+		nr.keys[0] = sep
+		nr.children[0] = t.root
+		nr.children[1] = split
+		t.root = nr
+	}
+	return old
+}
+
+// insert adds data under n.  If n overflows it is split, and the new right
+// sibling is returned along with the separator key for the parent.
+func (t *BTree) insert(n *btNode, data c.Comparable) (c.Comparable, *btNode, c.Comparable) {
+	if n.leaf() {
+		i := n.search(data, false)
+		if i < len(n.keys) && data.CompareTo(n.keys[i]) == 0 {
+			old := n.keys[i]
+			n.keys[i] = data
+			return old, nil, nil
+		}
+		n.keys = append(n.keys, nil)
+		copy(n.keys[i+1:], n.keys[i:])
+		n.keys[i] = data
+		t.size++
+		if len(n.keys) <= t.order {
+			return nil, nil, nil
+		}
+		m := len(n.keys) / 2
+		nn := &btNode{
+			keys: make([]c.Comparable, len(n.keys)-m, t.order+1),
+			prv:  n,
+			nxt:  n.nxt,
+		}
+		copy(nn.keys, n.keys[m:])
+		clearKeys(n.keys[m:])
+		n.keys = n.keys[:m]
+		if n.nxt != nil {
+			n.nxt.prv = nn
+		} else {
+			t.tail = nn
+		}
+		n.nxt = nn
+		return nil, nn, nn.keys[0]
+	}
+	i := n.search(data, true)
+	old, split, sep := t.insert(n.children[i], data)
+	if split == nil {
+		return old, nil, nil
+	}
+	n.keys = append(n.keys, nil)
+	copy(n.keys[i+1:], n.keys[i:])
+	n.keys[i] = sep
+	n.children = append(n.children, nil)
+	copy(n.children[i+2:], n.children[i+1:])
+	n.children[i+1] = split
+	if len(n.children) <= t.order {
+		return old, nil, nil
+	}
+	m := len(n.children) / 2
+	nn := &btNode{
+		keys:     make([]c.Comparable, len(n.keys)-m, t.order),
+		children: make([]*btNode, len(n.children)-m, t.order+1),
+	}
+	up := n.keys[m-1]
+	copy(nn.keys, n.keys[m:])
+	copy(nn.children, n.children[m:])
+	clearKeys(n.keys[m-1:])
+	clearChildren(n.children[m:])
+	n.keys = n.keys[:m-1]
+	n.children = n.children[:m]
+	return old, nn, up
+}
+
+// Delete removes an element from the tree.  If the argument is found, the
+// canonical value from the tree is returned along with boolean true.  If not,
+// the pair (nil,false) is returned.
+func (t *BTree) Delete(data c.Comparable) (c.Comparable, bool) {
+	if t.size == 0 {
+		return nil, false
+	}
+	old, found := t.delete(t.root, data)
+	if !t.root.leaf() && len(t.root.children) == 1 {
+		t.root = t.root.children[0]
+	}
+	return old, found
+}
+
+func (t *BTree) delete(n *btNode, data c.Comparable) (c.Comparable, bool) {
+	if n.leaf() {
+		i := n.search(data, false)
+		if i == len(n.keys) || data.CompareTo(n.keys[i]) != 0 {
+			return nil, false
+		}
+		old := n.keys[i]
+		copy(n.keys[i:], n.keys[i+1:])
+		n.keys[len(n.keys)-1] = nil
+		n.keys = n.keys[:len(n.keys)-1]
+		t.size--
+		return old, true
+	}
+	i := n.search(data, true)
+	old, found := t.delete(n.children[i], data)
+	if found {
+		t.rebalanceChild(n, i)
+	}
+	return old, found
+}
+
+// rebalanceChild restores the minimum occupancy of n.children[i] after a
+// deletion, by borrowing from or merging with an adjacent sibling.
+func (t *BTree) rebalanceChild(n *btNode, i int) {
+	cn := n.children[i]
+	var ln, rn *btNode
+	if i > 0 {
+		ln = n.children[i-1]
+	}
+	if i < len(n.children)-1 {
+		rn = n.children[i+1]
+	}
+	if cn.leaf() {
+		if len(cn.keys) >= t.minKeys() {
+			return
+		}
+		switch {
+		case ln != nil && len(ln.keys) > t.minKeys():
+			cn.keys = append(cn.keys, nil)
+			copy(cn.keys[1:], cn.keys)
+			cn.keys[0] = ln.keys[len(ln.keys)-1]
+			ln.keys[len(ln.keys)-1] = nil
+			ln.keys = ln.keys[:len(ln.keys)-1]
+			n.keys[i-1] = cn.keys[0]
+		case rn != nil && len(rn.keys) > t.minKeys():
+			cn.keys = append(cn.keys, rn.keys[0])
+			copy(rn.keys, rn.keys[1:])
+			rn.keys[len(rn.keys)-1] = nil
+			rn.keys = rn.keys[:len(rn.keys)-1]
+			n.keys[i] = rn.keys[0]
+		case ln != nil:
+			t.mergeLeaves(n, i-1)
+		case rn != nil:
+			t.mergeLeaves(n, i)
+		}
+		return
+	}
+	if len(cn.children) >= t.minChildren() {
+		return
+	}
+	switch {
+	case ln != nil && len(ln.children) > t.minChildren():
+		cn.keys = append(cn.keys, nil)
+		copy(cn.keys[1:], cn.keys)
+		cn.keys[0] = n.keys[i-1]
+		cn.children = append(cn.children, nil)
+		copy(cn.children[1:], cn.children)
+		cn.children[0] = ln.children[len(ln.children)-1]
+		n.keys[i-1] = ln.keys[len(ln.keys)-1]
+		ln.keys[len(ln.keys)-1] = nil
+		ln.keys = ln.keys[:len(ln.keys)-1]
+		ln.children[len(ln.children)-1] = nil
+		ln.children = ln.children[:len(ln.children)-1]
+	case rn != nil && len(rn.children) > t.minChildren():
+		cn.keys = append(cn.keys, n.keys[i])
+		cn.children = append(cn.children, rn.children[0])
+		n.keys[i] = rn.keys[0]
+		copy(rn.keys, rn.keys[1:])
+		rn.keys[len(rn.keys)-1] = nil
+		rn.keys = rn.keys[:len(rn.keys)-1]
+		copy(rn.children, rn.children[1:])
+		rn.children[len(rn.children)-1] = nil
+		rn.children = rn.children[:len(rn.children)-1]
+	case ln != nil:
+		t.mergeInner(n, i-1)
+	case rn != nil:
+		t.mergeInner(n, i)
+	}
+}
+
+// mergeLeaves merges leaf n.children[i+1] into n.children[i].
+func (t *BTree) mergeLeaves(n *btNode, i int) {
+	ln, rn := n.children[i], n.children[i+1]
+	ln.keys = append(ln.keys, rn.keys...)
+	ln.nxt = rn.nxt
+	if rn.nxt != nil {
+		rn.nxt.prv = ln
+	} else {
+		t.tail = ln
+	}
+	rn.nxt = nil
+	rn.prv = nil
+	rn.keys = nil
+	removeChild(n, i)
+}
+
+// mergeInner merges inner node n.children[i+1] into n.children[i], pulling
+// down the separator between them.
+func (t *BTree) mergeInner(n *btNode, i int) {
+	ln, rn := n.children[i], n.children[i+1]
+	ln.keys = append(ln.keys, n.keys[i])
+	ln.keys = append(ln.keys, rn.keys...)
+	ln.children = append(ln.children, rn.children...)
+	removeChild(n, i)
+}
+
+// removeChild removes separator n.keys[i] and child n.children[i+1].
+func removeChild(n *btNode, i int) {
+	copy(n.keys[i:], n.keys[i+1:])
+	n.keys[len(n.keys)-1] = nil
+	n.keys = n.keys[:len(n.keys)-1]
+	copy(n.children[i+1:], n.children[i+2:])
+	n.children[len(n.children)-1] = nil
+	n.children = n.children[:len(n.children)-1]
+}
+
+func clearKeys(keys []c.Comparable) {
+	for i := range keys {
+		keys[i] = nil
+	}
+}
+
+func clearChildren(children []*btNode) {
+	for i := range children {
+		children[i] = nil
+	}
+}
+
+// First opens a cursor positioned before the first value in the tree.
+func (t *BTree) First() c.Cursor {
+	return &btCursor{tree: t}
+}
+
+// Last opens a cursor positioned after the last value in the tree.
+func (t *BTree) Last() c.Cursor {
+	return &btCursor{tree: t, end: true}
+}
+
+// GetCursor opens a cursor whose first value will be the value that would have
+// been returned by an equivalent Lookup() call.  The iterator is bidirectional
+// and can range past the start of the search.  It is not fail-fast - changes
+// in the tree will change its behavior.
+func (t *BTree) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	leaf, i, exact := t.lookupPos(lt, data)
+	tc := &btCursor{
+		tree: t,
+		leaf: leaf,
+		idx:  i,
+	}
+	if leaf == nil && ascending(lt) {
+		tc.end = true
+	}
+	return tc, exact
+}
+
+// HasNext checks for the availability of a next data value from the cursor.
+func (c *btCursor) HasNext() bool {
+	return c.leaf != nil ||
+		(c.leaf == nil && !c.end && c.tree.size > 0)
+}
+
+// HasPrev checks for the availability of a previous data value from the cursor.
+func (c *btCursor) HasPrev() bool {
+	return (c.leaf != nil && (c.idx > 0 || c.leaf.prv != nil)) ||
+		(c.leaf == nil && c.end && c.tree.size > 0)
+}
+
+// Next retrieves the next value from the cursor.
+func (c *btCursor) Next() c.Comparable {
+	if c.leaf == nil {
+		if c.end || c.tree.size == 0 {
+			return nil
+		}
+		c.leaf = c.tree.head
+		c.idx = 0
+	}
+	val := c.leaf.keys[c.idx]
+	c.idx++
+	if c.idx == len(c.leaf.keys) {
+		c.leaf = c.leaf.nxt
+		c.idx = 0
+		if c.leaf == nil {
+			c.end = true
+		}
+	}
+	return val
+}
+
+// Prev retrieves the previous value from the cursor.  As with the AvlTree
+// cursor, switching direction returns the value most recently returned.
+func (c *btCursor) Prev() c.Comparable {
+	if c.leaf == nil {
+		if c.end && c.tree.size > 0 {
+			c.leaf = c.tree.tail
+			c.idx = len(c.leaf.keys) - 1
+			c.end = false
+			return c.leaf.keys[c.idx]
+		}
+		return nil
+	}
+	if c.idx > 0 {
+		c.idx--
+		return c.leaf.keys[c.idx]
+	}
+	c.leaf = c.leaf.prv
+	if c.leaf == nil {
+		return nil
+	}
+	c.idx = len(c.leaf.keys) - 1
+	return c.leaf.keys[c.idx]
+}
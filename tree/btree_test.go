@@ -0,0 +1,81 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// checkBtNode verifies the occupancy, ordering and separators of the subtree
+// rooted at n, whose values must lie in [lo, hi) (nil bounds are open).  It
+// returns the depth of the leaves and appends them to leaves in order.
+func checkBtNode(t *BTree, n *btNode, lo, hi c.Comparable, leaves []*btNode) (int, []*btNode) {
+	for i, k := range n.keys {
+		if lo != nil && k.CompareTo(lo) < 0 {
+			panic("key below subtree lower bound")
+		}
+		if hi != nil && k.CompareTo(hi) >= 0 {
+			panic("key above subtree upper bound")
+		}
+		if i > 0 && n.keys[i-1].CompareTo(k) >= 0 {
+			panic("key order violation")
+		}
+	}
+	if n.leaf() {
+		if len(n.keys) > t.order {
+			panic("leaf overflow")
+		}
+		if n != t.root && len(n.keys) < t.minKeys() {
+			panic("leaf underflow")
+		}
+		return 1, append(leaves, n)
+	}
+	if len(n.children) != len(n.keys)+1 {
+		panic("inner node key and child counts disagree")
+	}
+	if len(n.children) > t.order {
+		panic("inner node overflow")
+	}
+	if n != t.root && len(n.children) < t.minChildren() {
+		panic("inner node underflow")
+	}
+	depth := -1
+	for i, cn := range n.children {
+		clo, chi := lo, hi
+		if i > 0 {
+			clo = n.keys[i-1]
+		}
+		if i < len(n.keys) {
+			chi = n.keys[i]
+		}
+		var d int
+		d, leaves = checkBtNode(t, cn, clo, chi, leaves)
+		if depth >= 0 && d != depth {
+			panic("leaves at unequal depths")
+		}
+		depth = d
+	}
+	return depth + 1, leaves
+}
+
+func checkBTree(t *BTree) {
+	if t.root == nil {
+		if t.size != 0 {
+			panic("incorrect size in tree")
+		}
+		return
+	}
+	_, leaves := checkBtNode(t, t.root, nil, nil, nil)
+	if t.head != leaves[0] || t.tail != leaves[len(leaves)-1] {
+		panic("head or tail is not an end leaf")
+	}
+	var count uint
+	for i, n := range leaves {
+		if (i == 0 && n.prv != nil) || (i > 0 && n.prv != leaves[i-1]) {
+			panic("incorrect leaf predecessor link")
+		}
+		if (i == len(leaves)-1 && n.nxt != nil) || (i < len(leaves)-1 && n.nxt != leaves[i+1]) {
+			panic("incorrect leaf successor link")
+		}
+		count += uint(len(n.keys))
+	}
+	if count != t.size {
+		panic("incorrect size in tree")
+	}
+}
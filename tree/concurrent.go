@@ -0,0 +1,399 @@
+package tree
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	c "github.com/dtromb/collections"
+)
+
+// ConcurrentSkipList is a Tree which is safe for concurrent use by multiple
+// goroutines without external locking.  It is a lazy skip list: lookups and
+// cursors take no locks, and Insert and Delete lock only the few nodes
+// adjacent to the value they change, so writers to different parts of the
+// list proceed in parallel.
+//
+// Insert, Delete, Has and EQ lookups are linearizable: each appears to take
+// effect atomically at some point between its call and return.  The other
+// lookups, Size and cursors are weakly consistent.  A lookup returns a value
+// which was present at some point during the call.  A cursor never fails and
+// never returns a value twice in one direction; it returns values in order,
+// each of which was present at some point while the cursor was moving to it,
+// and it reflects some but not necessarily all of the changes made since it
+// was opened.  Size is exact when there are no concurrent writers.
+type ConcurrentSkipList struct {
+	head *csNode
+	size atomic.Int64
+}
+
+type csNode struct {
+	val         atomic.Pointer[c.Comparable]
+	next        []atomic.Pointer[csNode]
+	mu          sync.Mutex
+	marked      atomic.Bool
+	fullyLinked atomic.Bool
+}
+
+// csCursor sits in a gap described by a value: just after key if after is
+// set, and just before it otherwise.  A nil key puts it before the first
+// value, or after the last if end is set.  Moving the cursor searches for the
+// neighbouring value, so the cursor remains valid whatever is changed.
+type csCursor struct {
+	list  *ConcurrentSkipList
+	key   c.Comparable
+	after bool
+	end   bool
+	node  *csNode
+	last  *csNode
+}
+
+// NewConcurrentSkipList creates an empty ConcurrentSkipList.
+func NewConcurrentSkipList() *ConcurrentSkipList {
+	return &ConcurrentSkipList{head: &csNode{next: make([]atomic.Pointer[csNode], maxSkipLevel)}}
+}
+
+func (n *csNode) data() c.Comparable {
+	return *n.val.Load()
+}
+
+// valid checks whether n is in the list: it has been linked at every level
+// and not yet marked for deletion.
+func (n *csNode) valid() bool {
+	return n.fullyLinked.Load() && !n.marked.Load()
+}
+
+// find fills preds and succs with the nodes either side of x at each level,
+// returning the highest level at which succs holds x, or -1.
+func (sl *ConcurrentSkipList) find(x c.Comparable, preds, succs []*csNode) int {
+	found := -1
+	pred := sl.head
+	for l := maxSkipLevel - 1; l >= 0; l-- {
+		curr := pred.next[l].Load()
+		for curr != nil && x.CompareTo(curr.data()) > 0 {
+			pred, curr = curr, curr.next[l].Load()
+		}
+		if found < 0 && curr != nil && x.CompareTo(curr.data()) == 0 {
+			found = l
+		}
+		preds[l], succs[l] = pred, curr
+	}
+	return found
+}
+
+// ceil returns the first valid node holding a value >= x, or > x if strict,
+// or nil if there is none.
+func (sl *ConcurrentSkipList) ceil(x c.Comparable, strict bool) *csNode {
+	pred := sl.head
+	var curr *csNode
+	for l := maxSkipLevel - 1; l >= 0; l-- {
+		curr = pred.next[l].Load()
+		for curr != nil {
+			r := x.CompareTo(curr.data())
+			if r < 0 || r == 0 && !strict {
+				break
+			}
+			pred, curr = curr, curr.next[l].Load()
+		}
+	}
+	if curr != nil && !curr.valid() {
+		return curr.following()
+	}
+	return curr
+}
+
+// following returns the first valid node after n at the bottom level.
+func (n *csNode) following() *csNode {
+	n = n.next[0].Load()
+	for n != nil && !n.valid() {
+		n = n.next[0].Load()
+	}
+	return n
+}
+
+// floor returns the last valid node holding a value <= x, or < x if strict,
+// or nil if there is none.  A nil x finds the last node in the list.
+func (sl *ConcurrentSkipList) floor(x c.Comparable, strict bool) *csNode {
+	for {
+		pred := sl.head
+		for l := maxSkipLevel - 1; l >= 0; l-- {
+			curr := pred.next[l].Load()
+			for curr != nil {
+				if x != nil {
+					r := x.CompareTo(curr.data())
+					if r < 0 || r == 0 && strict {
+						break
+					}
+				}
+				pred, curr = curr, curr.next[l].Load()
+			}
+		}
+		if pred == sl.head {
+			return nil
+		}
+		if pred.valid() {
+			return pred
+		}
+		// The node found is being inserted or deleted; look before it.
+		x, strict = pred.data(), true
+	}
+}
+
+// Size returns the number of elements in the list.
+func (sl *ConcurrentSkipList) Size() uint {
+	return uint(max(sl.size.Load(), 0))
+}
+
+func (sl *ConcurrentSkipList) Has(data c.Comparable) bool {
+	_, has := sl.Lookup(c.EQ, data)
+	return has
+}
+
+// lookupNode returns the node a lookup of the given type finds, and whether
+// data itself is in the list.
+func (sl *ConcurrentSkipList) lookupNode(lt c.LookupType, data c.Comparable) (*csNode, bool) {
+	var n *csNode
+	switch lt {
+	case c.GTE, c.EQ:
+		n = sl.ceil(data, false)
+		return n, n != nil && data.CompareTo(n.data()) == 0
+	case c.LTE:
+		n = sl.floor(data, false)
+		return n, n != nil && data.CompareTo(n.data()) == 0
+	case c.GT:
+		n = sl.ceil(data, true)
+	case c.LT:
+		n = sl.floor(data, true)
+	}
+	eq := sl.ceil(data, false)
+	return n, eq != nil && data.CompareTo(eq.data()) == 0
+}
+
+// Lookup finds a value in the list according to the given parameters.
+func (sl *ConcurrentSkipList) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	n, exact := sl.lookupNode(lt, data)
+	if n == nil || (lt == c.EQ && !exact) {
+		return nil, exact
+	}
+	return n.data(), exact
+}
+
+// lockPreds locks the distinct nodes among preds[:top] from the bottom level
+// up, and checks that each is still valid and linked to succs (or to victim,
+// if it is not nil) at its level.  It returns the function which unlocks
+// them.
+func lockPreds(preds, succs []*csNode, top int, victim *csNode) (func(), bool) {
+	var locked []*csNode
+	unlock := func() {
+		for _, n := range locked {
+			n.mu.Unlock()
+		}
+	}
+	valid := true
+	var prev *csNode
+	for l := 0; valid && l < top; l++ {
+		pred, succ := preds[l], succs[l]
+		if victim != nil {
+			succ = victim
+		}
+		if pred != prev {
+			pred.mu.Lock()
+			locked = append(locked, pred)
+			prev = pred
+		}
+		valid = !pred.marked.Load() && pred.next[l].Load() == succ &&
+			(victim != nil || succ == nil || !succ.marked.Load())
+	}
+	return unlock, valid
+}
+
+// Insert adds a value to the list, replacing and returning any equal value.
+func (sl *ConcurrentSkipList) Insert(data c.Comparable) c.Comparable {
+	var preds, succs [maxSkipLevel]*csNode
+	top := skipLevel(rand.Uint64())
+	for {
+		if found := sl.find(data, preds[:], succs[:]); found >= 0 {
+			n := succs[found]
+			if n.marked.Load() {
+				// Being deleted; retry once it is gone.
+				runtime.Gosched()
+				continue
+			}
+			for !n.fullyLinked.Load() {
+				runtime.Gosched()
+			}
+			n.mu.Lock()
+			if n.marked.Load() {
+				n.mu.Unlock()
+				continue
+			}
+			old := n.val.Swap(&data)
+			n.mu.Unlock()
+			return *old
+		}
+		unlock, valid := lockPreds(preds[:], succs[:], top, nil)
+		if !valid {
+			unlock()
+			continue
+		}
+		nn := &csNode{next: make([]atomic.Pointer[csNode], top)}
+		nn.val.Store(&data)
+		for l := 0; l < top; l++ {
+			nn.next[l].Store(succs[l])
+		}
+		for l := 0; l < top; l++ {
+			preds[l].next[l].Store(nn)
+		}
+		nn.fullyLinked.Store(true)
+		sl.size.Add(1)
+		unlock()
+		return nil
+	}
+}
+
+// Delete removes an element from the list.  If the argument is found, the
+// value from the list is returned along with boolean true.  If not, nil and
+// false is returned.
+func (sl *ConcurrentSkipList) Delete(data c.Comparable) (c.Comparable, bool) {
+	var preds, succs [maxSkipLevel]*csNode
+	var victim *csNode
+	for {
+		found := sl.find(data, preds[:], succs[:])
+		if victim == nil {
+			if found < 0 {
+				return nil, false
+			}
+			n := succs[found]
+			if !n.fullyLinked.Load() || n.marked.Load() || found != len(n.next)-1 {
+				// Not yet (or no longer) in the list.
+				return nil, false
+			}
+			n.mu.Lock()
+			if n.marked.Load() {
+				n.mu.Unlock()
+				return nil, false
+			}
+			// Marking the node is the linearization point; it is then
+			// unlinked from the top level down.
+			n.marked.Store(true)
+			victim = n
+		}
+		unlock, valid := lockPreds(preds[:], succs[:], len(victim.next), victim)
+		if !valid {
+			unlock()
+			continue
+		}
+		for l := len(victim.next) - 1; l >= 0; l-- {
+			preds[l].next[l].Store(victim.next[l].Load())
+		}
+		victim.mu.Unlock()
+		sl.size.Add(-1)
+		unlock()
+		return victim.data(), true
+	}
+}
+
+// First opens a cursor positioned before the first value in the list.
+func (sl *ConcurrentSkipList) First() c.Cursor {
+	return &csCursor{list: sl}
+}
+
+// Last opens a cursor positioned after the last value in the list.
+func (sl *ConcurrentSkipList) Last() c.Cursor {
+	return &csCursor{list: sl, end: true}
+}
+
+// GetCursor opens a cursor whose first value will be the value that would have
+// been returned by an equivalent Lookup() call.  The cursor is weakly
+// consistent, as described for ConcurrentSkipList.
+func (sl *ConcurrentSkipList) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	n, exact := sl.lookupNode(lt, data)
+	cur := &csCursor{list: sl}
+	switch {
+	case ascending(lt):
+		cur.key, cur.after = data, lt == c.GT
+	case n != nil:
+		cur.key, cur.node = n.data(), n
+	}
+	return cur, exact
+}
+
+// peekNext returns the node Next would move to.
+func (cc *csCursor) peekNext() *csNode {
+	switch {
+	case cc.key == nil && cc.end:
+		return nil
+	case cc.key == nil:
+		return cc.list.head.following()
+	case cc.after && cc.node != nil && cc.node.valid():
+		return cc.node.following()
+	}
+	return cc.list.ceil(cc.key, cc.after)
+}
+
+// peekPrev returns the node Prev would move to.
+func (cc *csCursor) peekPrev() *csNode {
+	switch {
+	case cc.key == nil && cc.end:
+		return cc.list.floor(nil, false)
+	case cc.key == nil:
+		return nil
+	}
+	return cc.list.floor(cc.key, !cc.after)
+}
+
+func (cc *csCursor) HasNext() bool { return cc.peekNext() != nil }
+
+func (cc *csCursor) HasPrev() bool { return cc.peekPrev() != nil }
+
+// Next retrieves the next value from the cursor.
+func (cc *csCursor) Next() c.Comparable {
+	n := cc.peekNext()
+	cc.last = n
+	if n == nil {
+		cc.key, cc.node, cc.end = nil, nil, true
+		return nil
+	}
+	cc.key, cc.after, cc.node = n.data(), true, n
+	return cc.key
+}
+
+// Prev retrieves the previous value from the cursor.  As for the other trees,
+// Prev after Next returns the same value again.
+func (cc *csCursor) Prev() c.Comparable {
+	n := cc.peekPrev()
+	cc.last = n
+	if n == nil {
+		cc.key, cc.node, cc.end = nil, nil, false
+		return nil
+	}
+	cc.key, cc.after, cc.node = n.data(), false, n
+	return cc.key
+}
+
+// Remove deletes the value most recently returned from the list, leaving the
+// cursor in the gap where it was.
+func (cc *csCursor) Remove() {
+	if cc.last == nil {
+		panic("Remove() without a current value")
+	}
+	cc.list.Delete(cc.last.data())
+	cc.last = nil
+}
+
+// Set replaces the value most recently returned with v, which must compare
+// equal to it.  If the value has since been deleted, Set does nothing.
+func (cc *csCursor) Set(v c.Comparable) {
+	if cc.last == nil {
+		panic("Set() without a current value")
+	}
+	if v.CompareTo(cc.last.data()) != 0 {
+		panic("Set() value does not compare equal")
+	}
+	cc.last.mu.Lock()
+	if !cc.last.marked.Load() {
+		cc.last.val.Store(&v)
+	}
+	cc.last.mu.Unlock()
+}
@@ -0,0 +1,181 @@
+package tree
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	c "github.com/dtromb/collections"
+)
+
+// checkConcurrentSkipList panics unless the list is quiescent and well formed:
+// each level is sorted and a sublist of the one below, and every node is
+// fully linked, unmarked and counted.
+func checkConcurrentSkipList(sl *ConcurrentSkipList) {
+	var n int
+	for l := maxSkipLevel - 1; l >= 0; l-- {
+		var prev *csNode
+		for cn := sl.head.next[l].Load(); cn != nil; cn = cn.next[l].Load() {
+			if !cn.valid() {
+				panic("invalid node reachable in quiescent list")
+			}
+			if len(cn.next) <= l {
+				panic("node linked above its top level")
+			}
+			if prev != nil {
+				if prev.data().CompareTo(cn.data()) >= 0 {
+					panic("level out of order")
+				}
+				if l > 0 && !reachable(prev, cn, l-1) {
+					panic("level is not a sublist of the one below")
+				}
+			}
+			if l == 0 {
+				n++
+			}
+			prev = cn
+		}
+	}
+	if uint(n) != sl.Size() {
+		panic(fmt.Sprintf("size %d, but %d nodes linked", sl.Size(), n))
+	}
+}
+
+// reachable checks whether to follows from at level l.
+func reachable(from, to *csNode, l int) bool {
+	for n := from; n != nil; n = n.next[l].Load() {
+		if n == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TestConcurrentSkipListStress runs writers and readers over the list at once.
+// Each writer owns the values congruent to its index, so that the final
+// contents can be checked against per-writer models; readers check that
+// lookups and cursors stay consistent with the ordering while the list
+// changes under them.  Run with -race.
+func TestConcurrentSkipListStress(t *testing.T) {
+	const writers, readers, ops, N = 4, 4, 20000, 2000
+	sl := NewConcurrentSkipList()
+	models := make([]map[ComparableInt]bool, writers)
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		models[w] = make(map[ComparableInt]bool)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			model := models[w]
+			for i := 0; i < ops; i++ {
+				x := ComparableInt(rnd.Intn(N/writers)*writers + w)
+				if rnd.Intn(3) == 0 {
+					if _, found := sl.Delete(x); found != model[x] {
+						t.Errorf("Delete(%d) returned %v", x, found)
+						return
+					}
+					delete(model, x)
+				} else {
+					if old := sl.Insert(x); (old != nil) != model[x] {
+						t.Errorf("Insert(%d) returned %v", x, old)
+						return
+					}
+					model[x] = true
+				}
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	var rwg sync.WaitGroup
+	for r := 0; r < readers; r++ {
+		rwg.Add(1)
+		go func(r int) {
+			defer rwg.Done()
+			rnd := rand.New(rand.NewSource(int64(100 + r)))
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				x := ComparableInt(rnd.Intn(N))
+				if v, _ := sl.Lookup(c.GTE, x); v != nil && v.CompareTo(x) < 0 {
+					t.Errorf("Lookup(GTE, %d) returned %v", x, v)
+				}
+				if v, _ := sl.Lookup(c.LT, x); v != nil && v.CompareTo(x) >= 0 {
+					t.Errorf("Lookup(LT, %d) returned %v", x, v)
+				}
+				var cur c.Cursor
+				var step func() c.Comparable
+				var dir int8
+				if r%2 == 0 {
+					cur, _ = sl.GetCursor(c.GTE, x)
+					step, dir = cur.Next, 1
+				} else {
+					cur, _ = sl.GetCursor(c.LTE, x)
+					step, dir = cur.Prev, -1
+				}
+				var prev c.Comparable
+				for i := 0; i < 50; i++ {
+					v := step()
+					if v == nil {
+						break
+					}
+					if prev != nil && v.CompareTo(prev) != dir {
+						t.Errorf("cursor returned %v after %v", v, prev)
+					}
+					prev = v
+				}
+			}
+		}(r)
+	}
+	wg.Wait()
+	close(done)
+	rwg.Wait()
+	checkConcurrentSkipList(sl)
+	model := make(map[ComparableInt]bool)
+	for _, m := range models {
+		for x := range m {
+			model[x] = true
+		}
+	}
+	checkContents(t, sl, model, N)
+}
+
+// TestConcurrentSkipListContention has every goroutine insert and delete the
+// same few values, so that operations on equal values race with each other.
+func TestConcurrentSkipListContention(t *testing.T) {
+	const workers, ops = 8, 3000
+	sl := NewConcurrentSkipList()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var net int
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(int64(w)))
+			var added int
+			for i := 0; i < ops; i++ {
+				x := ComparableInt(rnd.Intn(8))
+				if rnd.Intn(2) == 0 {
+					if sl.Insert(x) == nil {
+						added++
+					}
+				} else if _, found := sl.Delete(x); found {
+					added--
+				}
+			}
+			mu.Lock()
+			net += added
+			mu.Unlock()
+		}(w)
+	}
+	wg.Wait()
+	checkConcurrentSkipList(sl)
+	if int(sl.Size()) != net {
+		t.Errorf("size %d after a net %d insertions", sl.Size(), net)
+	}
+}
@@ -0,0 +1,215 @@
+package tree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"iter"
+	"slices"
+
+	c "github.com/dtromb/collections"
+)
+
+// The binary encoding of a sorted collection is a length-prefixed stream of
+// its elements in strictly ascending order, so that it can be reloaded in
+// linear time without comparing values beyond checking their order:
+//
+//	magic     "DTSS"
+//	version   one byte, currently 1
+//	count     uvarint
+//	elements  count times: a uvarint length, then that many bytes from the codec
+//	checksum  CRC-32 (Castagnoli) of all the preceding bytes, little-endian
+
+const sortedVersion = 1
+
+var sortedMagic = [4]byte{'D', 'T', 'S', 'S'}
+
+var sortedTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// errSortedFormat is returned for a malformed or misordered stream.
+	errSortedFormat = errors.New("tree: malformed sorted stream")
+	// errSortedVersion is returned for a stream written by a later format.
+	errSortedVersion = errors.New("tree: unsupported sorted stream version")
+	// errSortedChecksum is returned for a stream which has been corrupted.
+	errSortedChecksum = errors.New("tree: sorted stream checksum mismatch")
+	// errNoCodec is returned when there is no codec for the elements.
+	errNoCodec = errors.New("tree: no element codec")
+)
+
+// EncodeSorted encodes the n values of a sequence, which must be in strictly
+// ascending order, as a sorted stream with each element encoded by codec.  It
+// panics if the sequence does not hold n values.
+func EncodeSorted[T any](n uint, vals iter.Seq[T], codec c.Codec[T]) ([]byte, error) {
+	if codec == nil {
+		return nil, errNoCodec
+	}
+	buf := append(sortedMagic[:len(sortedMagic):len(sortedMagic)], sortedVersion)
+	buf = binary.AppendUvarint(buf, uint64(n))
+	var elem []byte
+	var err error
+	for v := range vals {
+		if n == 0 {
+			panic("EncodeSorted() sequence longer than count")
+		}
+		n--
+		if elem, err = codec.Append(elem[:0], v); err != nil {
+			return nil, err
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(elem)))
+		buf = append(buf, elem...)
+	}
+	if n != 0 {
+		panic("EncodeSorted() sequence shorter than count")
+	}
+	return binary.LittleEndian.AppendUint32(buf, crc32.Checksum(buf, sortedTable)), nil
+}
+
+// DecodeSorted decodes a sorted stream written by EncodeSorted, with each
+// element decoded by codec, and returns the values in order.  It returns an
+// error if the stream is corrupt, truncated, written by a later version of
+// the format, or holds values which are not strictly ascending by cmp.
+func DecodeSorted[T any](data []byte, codec c.Codec[T], cmp func(a, b T) int) ([]T, error) {
+	if codec == nil {
+		return nil, errNoCodec
+	}
+	if len(data) < len(sortedMagic)+5 || [4]byte(data) != sortedMagic {
+		return nil, errSortedFormat
+	}
+	if data[len(sortedMagic)] != sortedVersion {
+		return nil, errSortedVersion
+	}
+	body := data[:len(data)-4]
+	if crc32.Checksum(body, sortedTable) != binary.LittleEndian.Uint32(data[len(body):]) {
+		return nil, errSortedChecksum
+	}
+	body = body[len(sortedMagic)+1:]
+	n, k := binary.Uvarint(body)
+	// Every element takes at least a byte, which bounds the allocation.
+	if k <= 0 || n > uint64(len(body)-k) {
+		return nil, errSortedFormat
+	}
+	body = body[k:]
+	vals := make([]T, 0, n)
+	for range n {
+		l, k := binary.Uvarint(body)
+		if k <= 0 || l > uint64(len(body)-k) {
+			return nil, errSortedFormat
+		}
+		v, err := codec.Decode(body[k : k+int(l)])
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) > 0 && cmp(vals[len(vals)-1], v) >= 0 {
+			return nil, errSortedFormat
+		}
+		vals = append(vals, v)
+		body = body[k+int(l):]
+	}
+	if len(body) != 0 {
+		return nil, errSortedFormat
+	}
+	return vals, nil
+}
+
+// SetCodec sets the codec which MarshalBinary and UnmarshalBinary use for the
+// elements of the tree.  Trees derived from this one by Clone, Split and the
+// set operations keep it.
+func (t *AvlTreeOf[T]) SetCodec(codec c.Codec[T]) {
+	t.codec = codec
+}
+
+// Codec returns the codec set by SetCodec, or nil.
+func (t *AvlTreeOf[T]) Codec() c.Codec[T] {
+	return t.codec
+}
+
+// MarshalBinary encodes the tree as a sorted stream, as for EncodeSorted, in
+// O(n) time.  It implements encoding.BinaryMarshaler.
+func (t *AvlTreeOf[T]) MarshalBinary() ([]byte, error) {
+	return EncodeSorted(t.size, t.All(), t.codec)
+}
+
+// UnmarshalBinary replaces the contents of the tree with the values of a
+// sorted stream, decoded by the tree's codec, building it in O(n) time.  The
+// tree is left unchanged if the stream cannot be decoded.  It implements
+// encoding.BinaryUnmarshaler.
+func (t *AvlTreeOf[T]) UnmarshalBinary(data []byte) error {
+	vals, err := DecodeSorted(data, t.codec, t.compare)
+	if err != nil {
+		return err
+	}
+	t.build(vals)
+	return nil
+}
+
+// MarshalJSON encodes the elements of the tree as a JSON array in ascending
+// order.  The elements of an AvlTree are encoded by c.MarshalJSONElements,
+// naming their registered types; those of other trees are encoded as
+// themselves.  It implements json.Marshaler.
+func (t *AvlTreeOf[T]) MarshalJSON() ([]byte, error) {
+	if seq, ok := any(t.All()).(iter.Seq[c.Comparable]); ok {
+		return c.MarshalJSONElements(seq)
+	}
+	return json.Marshal(slices.Collect(t.All()))
+}
+
+// UnmarshalJSON replaces the contents of the tree with the elements of a JSON
+// array encoded as by MarshalJSON, which need not be in order.  It implements
+// json.Unmarshaler.
+func (t *AvlTreeOf[T]) UnmarshalJSON(data []byte) error {
+	var vals []T
+	var err error
+	if _, ok := any(vals).([]c.Comparable); ok {
+		var cvs []c.Comparable
+		cvs, err = c.UnmarshalJSONElements(data)
+		vals = any(cvs).([]T)
+	} else {
+		err = json.Unmarshal(data, &vals)
+	}
+	if err != nil {
+		return err
+	}
+	t.rebuild(vals)
+	return nil
+}
+
+// GobEncode encodes the elements of the tree in ascending order, in the same
+// manner as MarshalJSON.  It implements gob.GobEncoder.
+func (t *AvlTreeOf[T]) GobEncode() ([]byte, error) {
+	if seq, ok := any(t.All()).(iter.Seq[c.Comparable]); ok {
+		return c.GobEncodeElements(seq)
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(slices.Collect(t.All()))
+	return buf.Bytes(), err
+}
+
+// GobDecode replaces the contents of the tree with the elements encoded by
+// GobEncode.  It implements gob.GobDecoder.
+func (t *AvlTreeOf[T]) GobDecode(data []byte) error {
+	var vals []T
+	var err error
+	if _, ok := any(vals).([]c.Comparable); ok {
+		var cvs []c.Comparable
+		cvs, err = c.GobDecodeElements(data)
+		vals = any(cvs).([]T)
+	} else {
+		err = gob.NewDecoder(bytes.NewReader(data)).Decode(&vals)
+	}
+	if err != nil {
+		return err
+	}
+	t.rebuild(vals)
+	return nil
+}
+
+// rebuild replaces the contents of the tree with vals in any order, of which
+// the last of equal values is kept, in O(n ln(n)) time.
+func (t *AvlTreeOf[T]) rebuild(vals []T) {
+	slices.SortStableFunc(vals, t.compare)
+	t.build(vals)
+}
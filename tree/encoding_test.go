@@ -0,0 +1,183 @@
+package tree
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"slices"
+	"testing"
+
+	c "github.com/dtromb/collections"
+)
+
+// intCodec encodes ComparableInt values as varints.
+type intCodec struct{}
+
+func (intCodec) Append(buf []byte, v c.Comparable) ([]byte, error) {
+	return binary.AppendVarint(buf, int64(v.(ComparableInt))), nil
+}
+
+func (intCodec) Decode(data []byte) (c.Comparable, error) {
+	x, k := binary.Varint(data)
+	if k != len(data) {
+		return nil, errors.New("bad varint")
+	}
+	return ComparableInt(x), nil
+}
+
+// reseal replaces the checksum of an encoded stream after it has been edited.
+func reseal(data []byte) []byte {
+	body := data[:len(data)-4]
+	return binary.LittleEndian.AppendUint32(body, crc32.Checksum(body, sortedTable))
+}
+
+func TestAvlTreeBinary(t *testing.T) {
+	var _ encoding.BinaryMarshaler = &AvlTree{}
+	var _ encoding.BinaryUnmarshaler = &AvlTree{}
+	for _, n := range []int{0, 1, 2, 100, 5000} {
+		at := &AvlTree{}
+		at.SetCodec(intCodec{})
+		for _, k := range rand.New(rand.NewSource(int64(n))).Perm(n) {
+			at.Insert(ComparableInt(k - n/2))
+		}
+		data, err := at.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rt := &AvlTree{}
+		rt.SetCodec(intCodec{})
+		rt.Insert(ComparableInt(-1 << 40))
+		if err := rt.UnmarshalBinary(data); err != nil {
+			t.Fatalf("n=%d: %v", n, err)
+		}
+		checkTree(rt)
+		if rt.Size() != uint(n) {
+			t.Fatalf("n=%d: decoded %d values", n, rt.Size())
+		}
+		for i, v := 0, rt.First(); v.HasNext(); i++ {
+			if x := v.Next(); x != ComparableInt(i-n/2) {
+				t.Fatalf("n=%d: decoded %v at %d", n, x, i)
+			}
+		}
+	}
+
+	at := &AvlTree{}
+	if _, err := at.MarshalBinary(); err != errNoCodec {
+		t.Errorf("MarshalBinary() without codec returned %v", err)
+	}
+	at.SetCodec(intCodec{})
+	for _, k := range []int{3, 1, 4, 15, 9, 2, 6} {
+		at.Insert(ComparableInt(k))
+	}
+	good, _ := at.MarshalBinary()
+	if at.Clone().Codec() == nil {
+		t.Error("clone lost the codec")
+	}
+	edit := func(f func(b []byte) []byte) []byte {
+		return f(append([]byte(nil), good...))
+	}
+	// The seven values follow the header, each a length byte and a varint.
+	const first = 6
+	bad := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, errSortedFormat},
+		{"magic", edit(func(b []byte) []byte { b[0] = 'X'; return reseal(b) }), errSortedFormat},
+		{"version", edit(func(b []byte) []byte { b[4] = 2; return reseal(b) }), errSortedVersion},
+		{"flipped", edit(func(b []byte) []byte { b[first+1] ^= 4; return b }), errSortedChecksum},
+		{"truncated", edit(func(b []byte) []byte { return reseal(b[:len(b)-6]) }), errSortedFormat},
+		{"trailing", edit(func(b []byte) []byte { return reseal(append(b, 0, 0, 0, 0, 0)) }), errSortedFormat},
+		{"count", edit(func(b []byte) []byte { b[5] = 100; return reseal(b) }), errSortedFormat},
+		{"order", edit(func(b []byte) []byte { b[first+1], b[first+3] = b[first+3], b[first+1]; return reseal(b) }), errSortedFormat},
+		{"length", edit(func(b []byte) []byte { b[first] = 99; return reseal(b) }), errSortedFormat},
+	}
+	for _, tc := range bad {
+		rt := &AvlTree{}
+		rt.SetCodec(intCodec{})
+		rt.Insert(ComparableInt(42))
+		if err := rt.UnmarshalBinary(tc.data); err != tc.err {
+			t.Errorf("%s: UnmarshalBinary() returned %v, expected %v", tc.name, err, tc.err)
+		}
+		if rt.Size() != 1 || !rt.Has(ComparableInt(42)) {
+			t.Errorf("%s: failed UnmarshalBinary() changed the tree", tc.name)
+		}
+	}
+}
+
+func init() {
+	c.Register[ComparableInt]("tree.ComparableInt")
+}
+
+func TestAvlTreeJSONGob(t *testing.T) {
+	at := &AvlTree{}
+	for _, k := range []int{5, -3, 8, 0} {
+		at.Insert(ComparableInt(k))
+	}
+	data, err := json.Marshal(at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"type":"tree.ComparableInt","value":-3},{"type":"tree.ComparableInt","value":0},` +
+		`{"type":"tree.ComparableInt","value":5},{"type":"tree.ComparableInt","value":8}]`
+	if string(data) != want {
+		t.Errorf("MarshalJSON() returned %s", data)
+	}
+	rt := &AvlTree{}
+	rt.Insert(ComparableInt(99))
+	// Elements may arrive in any order and with duplicates.
+	in := `[{"type":"tree.ComparableInt","value":8},{"type":"tree.ComparableInt","value":-3},` +
+		`{"type":"tree.ComparableInt","value":8}]`
+	if err := json.Unmarshal([]byte(in), rt); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(rt)
+	if rt.Size() != 2 || !rt.Has(ComparableInt(-3)) || !rt.Has(ComparableInt(8)) {
+		t.Errorf("UnmarshalJSON() gave %v", slices.Collect(rt.All()))
+	}
+	if err := json.Unmarshal([]byte(`[{"type":"nope","value":1}]`), rt); err == nil {
+		t.Error("unregistered type decoded")
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(at); err != nil {
+		t.Fatal(err)
+	}
+	gt := &AvlTree{}
+	if err := gob.NewDecoder(&buf).Decode(gt); err != nil {
+		t.Fatal(err)
+	}
+	checkTree(gt)
+	if fmt.Sprint(slices.Collect(gt.All())) != fmt.Sprint(slices.Collect(at.All())) {
+		t.Errorf("gob round trip gave %v", slices.Collect(gt.All()))
+	}
+
+	// Trees of other element types are encoded as plain values.
+	ot := NewOrderedAvlTree[string]()
+	for _, s := range []string{"pear", "apple", "fig"} {
+		ot.Insert(s)
+	}
+	data, _ = json.Marshal(ot)
+	if string(data) != `["apple","fig","pear"]` {
+		t.Errorf("MarshalJSON() returned %s", data)
+	}
+	rot := NewOrderedAvlTree[string]()
+	if err := json.Unmarshal([]byte(`["b","a","c"]`), rot); err != nil || fmt.Sprint(slices.Collect(rot.All())) != "[a b c]" {
+		t.Errorf("UnmarshalJSON() gave %v, %v", slices.Collect(rot.All()), err)
+	}
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(ot); err != nil {
+		t.Fatal(err)
+	}
+	rot = NewOrderedAvlTree[string]()
+	if err := gob.NewDecoder(&buf).Decode(rot); err != nil || fmt.Sprint(slices.Collect(rot.All())) != "[apple fig pear]" {
+		t.Errorf("gob round trip gave %v, %v", slices.Collect(rot.All()), err)
+	}
+}
@@ -0,0 +1,145 @@
+package tree
+
+import (
+	"slices"
+
+	c "github.com/dtromb/collections"
+)
+
+// Interval is the closed range [Lo, Hi] of Comparable values, so that an
+// interval with Lo equal to Hi holds a single point.  (A set.Range, by
+// contrast, is half-open.)  Intervals are ordered by Lo and then by Hi.
+type Interval struct {
+	Lo, Hi c.Comparable
+}
+
+func (iv Interval) CompareTo(o c.Comparable) int8 {
+	oi := o.(Interval)
+	if r := iv.Lo.CompareTo(oi.Lo); r != 0 {
+		return r
+	}
+	return iv.Hi.CompareTo(oi.Hi)
+}
+
+// Overlaps checks whether the interval has any point in common with the
+// closed range [a, b], which is empty if b is before a.
+func (iv Interval) Overlaps(a, b c.Comparable) bool {
+	return a.CompareTo(b) <= 0 && iv.Lo.CompareTo(b) <= 0 && iv.Hi.CompareTo(a) >= 0
+}
+
+// IntervalTree holds a set of Intervals and finds those which overlap a range
+// or contain a point in O(ln(n)+k) time for k results.  It is an AVL tree
+// ordered by interval, augmented with the greatest Hi in each subtree.
+type IntervalTree struct {
+	tree *AvlTreeOf[ivEntry]
+}
+
+// ivEntry is the element of the tree behind an IntervalTree: an interval
+// along with the greatest Hi in its subtree, which the tree's augment
+// function keeps up to date.
+type ivEntry struct {
+	iv    Interval
+	maxHi c.Comparable
+}
+
+type ivCursor struct {
+	cursor c.CursorOf[ivEntry]
+}
+
+// NewIntervalTree creates an empty IntervalTree.
+func NewIntervalTree() *IntervalTree {
+	t := NewAvlTreeOf(func(a, b ivEntry) int { return int(a.iv.CompareTo(b.iv)) })
+	t.augment = func(n *avlNode[ivEntry]) {
+		hi := n.data.iv.Hi
+		for _, ch := range []*avlNode[ivEntry]{n.l, n.r} {
+			if ch != nil && ch.data.maxHi.CompareTo(hi) > 0 {
+				hi = ch.data.maxHi
+			}
+		}
+		n.data.maxHi = hi
+	}
+	return &IntervalTree{tree: t}
+}
+
+// Size returns the number of intervals in the tree.
+func (it *IntervalTree) Size() uint {
+	return it.tree.Size()
+}
+
+// Has checks whether the tree holds the interval.
+func (it *IntervalTree) Has(iv Interval) bool {
+	return it.tree.Has(ivEntry{iv: iv})
+}
+
+// Insert adds an interval to the tree, returning false if it was already
+// present.  It panics if iv.Lo is after iv.Hi.
+func (it *IntervalTree) Insert(iv Interval) bool {
+	if iv.Lo.CompareTo(iv.Hi) > 0 {
+		panic("Interval bounds out of order")
+	}
+	return it.tree.Insert(ivEntry{iv: iv}).iv.Lo == nil
+}
+
+// Delete removes an interval from the tree, returning whether it was present.
+func (it *IntervalTree) Delete(iv Interval) bool {
+	_, found := it.tree.Delete(ivEntry{iv: iv})
+	return found
+}
+
+// First opens a cursor positioned before the first interval in the tree.
+func (it *IntervalTree) First() c.Cursor {
+	return &ivCursor{cursor: it.tree.First()}
+}
+
+// Overlapping opens a cursor over the intervals which have a point in common
+// with the closed range [a, b], in order; there are none if b is before a.
+// It holds a snapshot of the matches, which is unaffected by later changes to
+// the tree.
+func (it *IntervalTree) Overlapping(a, b c.Comparable) c.Cursor {
+	var found []c.Comparable
+	if a.CompareTo(b) > 0 {
+		return c.FromSeq(slices.Values(found))
+	}
+	var visit func(n *avlNode[ivEntry])
+	visit = func(n *avlNode[ivEntry]) {
+		// No interval under n reaches a.
+		if n == nil || n.data.maxHi.CompareTo(a) < 0 {
+			return
+		}
+		visit(n.l)
+		iv := n.data.iv
+		// Neither n nor any interval after it starts by b.
+		if iv.Lo.CompareTo(b) > 0 {
+			return
+		}
+		if iv.Hi.CompareTo(a) >= 0 {
+			found = append(found, iv)
+		}
+		visit(n.r)
+	}
+	visit(it.tree.root)
+	return c.FromSeq(slices.Values(found))
+}
+
+// Stabbing opens a cursor over the intervals which contain the point p, as
+// for Overlapping(p, p).
+func (it *IntervalTree) Stabbing(p c.Comparable) c.Cursor {
+	return it.Overlapping(p, p)
+}
+
+// interval returns the interval of an entry returned by the tree's cursor, or
+// nil for the zero entry returned past either end.
+func (e ivEntry) interval() c.Comparable {
+	if e.iv.Lo == nil {
+		return nil
+	}
+	return e.iv
+}
+
+func (ic *ivCursor) HasNext() bool { return ic.cursor.HasNext() }
+
+func (ic *ivCursor) HasPrev() bool { return ic.cursor.HasPrev() }
+
+func (ic *ivCursor) Next() c.Comparable { return ic.cursor.Next().interval() }
+
+func (ic *ivCursor) Prev() c.Comparable { return ic.cursor.Prev().interval() }
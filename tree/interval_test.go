@@ -0,0 +1,135 @@
+package tree
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+
+	c "github.com/dtromb/collections"
+)
+
+// checkMaxHi verifies the links, subtree size and augmented value of each
+// node under n, returning the greatest Hi in the subtree.
+func checkMaxHi(t *testing.T, n *avlNode[ivEntry]) ComparableInt {
+	hi := n.data.iv.Hi.(ComparableInt)
+	for _, ch := range []*avlNode[ivEntry]{n.l, n.r} {
+		if ch != nil {
+			if ch.p != n {
+				t.Fatalf("child of %v not linked to it", n.data.iv)
+			}
+			hi = max(hi, checkMaxHi(t, ch))
+		}
+	}
+	if n.size != n.l.count()+n.r.count()+1 {
+		t.Fatalf("node %v has size %d", n.data.iv, n.size)
+	}
+	if n.data.maxHi != hi {
+		t.Fatalf("node %v has max end %v, expected %v", n.data.iv, n.data.maxHi, hi)
+	}
+	return hi
+}
+
+func TestIntervalTree(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	it := NewIntervalTree()
+	model := make(map[Interval]bool)
+	randomInterval := func() Interval {
+		lo := rnd.Intn(1000)
+		return Interval{ComparableInt(lo), ComparableInt(lo + rnd.Intn(60))}
+	}
+	for i := 0; i < 3000; i++ {
+		iv := randomInterval()
+		if rnd.Intn(3) == 0 {
+			if it.Delete(iv) != model[iv] {
+				t.Fatalf("Delete(%v) disagrees with model", iv)
+			}
+			delete(model, iv)
+		} else {
+			if it.Insert(iv) == model[iv] {
+				t.Fatalf("Insert(%v) disagrees with model", iv)
+			}
+			model[iv] = true
+		}
+		if it.Size() != uint(len(model)) {
+			t.Fatalf("size %d, expected %d", it.Size(), len(model))
+		}
+		if i%100 != 0 {
+			continue
+		}
+		if it.tree.root != nil {
+			checkMaxHi(t, it.tree.root)
+		}
+		for j := 0; j < 20; j++ {
+			q := randomInterval()
+			var want []string
+			cc := it.First()
+			for cc.HasNext() {
+				iv := cc.Next().(Interval)
+				if iv.Overlaps(q.Lo, q.Hi) {
+					want = append(want, fmt.Sprint(iv))
+				}
+			}
+			var got []string
+			for iv := range c.Values(it.Overlapping(q.Lo, q.Hi)) {
+				got = append(got, fmt.Sprint(iv))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("Overlapping(%v, %v) returned %v, expected %v", q.Lo, q.Hi, got, want)
+			}
+			got, want = nil, nil
+			for iv := range model {
+				if iv.Lo.CompareTo(q.Lo) <= 0 && iv.Hi.CompareTo(q.Lo) >= 0 {
+					want = append(want, fmt.Sprint(iv))
+				}
+			}
+			for iv := range c.Values(it.Stabbing(q.Lo)) {
+				got = append(got, fmt.Sprint(iv))
+			}
+			if len(got) != len(want) {
+				t.Fatalf("Stabbing(%v) returned %v, expected %v", q.Lo, got, want)
+			}
+		}
+	}
+
+	// Intervals are closed, so a single point may be stored and found, and
+	// intervals which touch overlap.  A reversed query matches nothing.
+	it = NewIntervalTree()
+	it.Insert(Interval{ComparableInt(1), ComparableInt(3)})
+	it.Insert(Interval{ComparableInt(3), ComparableInt(3)})
+	it.Insert(Interval{ComparableInt(3), ComparableInt(5)})
+	for _, q := range []struct {
+		a, b int
+		want string
+	}{
+		{3, 3, "[{1 3} {3 3} {3 5}]"},
+		{2, 2, "[{1 3}]"},
+		{4, 2, "[]"},
+		{6, 6, "[]"},
+	} {
+		got := fmt.Sprint(slices.Collect(c.Values(it.Overlapping(ComparableInt(q.a), ComparableInt(q.b)))))
+		if got != q.want {
+			t.Errorf("Overlapping(%d, %d) returned %v, expected %v", q.a, q.b, got, q.want)
+		}
+	}
+	iv := Interval{ComparableInt(2), ComparableInt(3)}
+	if !iv.Overlaps(ComparableInt(3), ComparableInt(3)) || iv.Overlaps(ComparableInt(3), ComparableInt(2)) {
+		t.Error("Overlaps mishandles a point or a reversed range")
+	}
+
+	// A bulk rebuild computes the augmented values too.
+	var entries []ivEntry
+	var want int
+	for i := 0; i < 100; i++ {
+		iv := Interval{ComparableInt(i), ComparableInt(i + 100 - i%7*10)}
+		entries = append(entries, ivEntry{iv: iv})
+		if iv.Hi.CompareTo(ComparableInt(150)) >= 0 {
+			want++
+		}
+	}
+	it.tree.Rebuild(entries)
+	checkMaxHi(t, it.tree.root)
+	if got := len(slices.Collect(c.Values(it.Stabbing(ComparableInt(150))))); got != want {
+		t.Errorf("Stabbing(150) after rebuild found %d intervals, expected %d", got, want)
+	}
+}
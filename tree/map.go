@@ -0,0 +1,151 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// OrderedMap is an ordered key/value map.  It is backed by a tree of entries,
+// each carrying a value alongside its key and ordered by the key alone, so
+// that the keys need only implement Comparable over themselves and the nodes
+// of trees which are not used as maps carry no value slot.
+type OrderedMap struct {
+	tree Tree
+}
+
+// EntryCursor is an iterator over the entries of an OrderedMap.  It moves
+// exactly as a Cursor over the keys does, returning each key along with its
+// value.
+type EntryCursor interface {
+	HasNext() bool
+	HasPrev() bool
+	Next() (c.Comparable, interface{})
+	Prev() (c.Comparable, interface{})
+}
+
+type mapCursor struct {
+	cursor c.Cursor
+}
+
+// mapEntry is an element of the tree behind an OrderedMap: a key with its
+// value, comparing as the key.  A lookup probes the tree with an entry
+// holding the key alone.
+type mapEntry struct {
+	key   c.Comparable
+	value interface{}
+}
+
+func (e mapEntry) CompareTo(o c.Comparable) int8 {
+	return e.key.CompareTo(o.(mapEntry).key)
+}
+
+// entryOf returns an element of the tree as an entry, or the zero entry for
+// the nil returned when there is none.
+func entryOf(x c.Comparable) mapEntry {
+	if x == nil {
+		return mapEntry{}
+	}
+	return x.(mapEntry)
+}
+
+// NewOrderedMap creates an empty map backed by a tree of the given
+// implementation (see NewTree).
+func NewOrderedMap(impl ...TreeImplementation) *OrderedMap {
+	return &OrderedMap{tree: NewTree(impl...)}
+}
+
+// Size returns the number of entries in the map.
+func (m *OrderedMap) Size() uint {
+	return m.tree.Size()
+}
+
+// Has checks whether the map holds an entry for the key.
+func (m *OrderedMap) Has(key c.Comparable) bool {
+	return m.tree.Has(mapEntry{key: key})
+}
+
+// Put associates a value with a key.  If the key was already present its
+// previous value is returned along with boolean true.
+func (m *OrderedMap) Put(key c.Comparable, value interface{}) (interface{}, bool) {
+	old := entryOf(m.tree.Insert(mapEntry{key, value}))
+	return old.value, old.key != nil
+}
+
+// Get returns the value associated with a key, and whether there was one.
+func (m *OrderedMap) Get(key c.Comparable) (interface{}, bool) {
+	e, exact := m.tree.Lookup(c.EQ, mapEntry{key: key})
+	return entryOf(e).value, exact
+}
+
+// Remove deletes the entry for a key, returning its value along with boolean
+// true if there was one.
+func (m *OrderedMap) Remove(key c.Comparable) (interface{}, bool) {
+	e, found := m.tree.Delete(mapEntry{key: key})
+	return entryOf(e).value, found
+}
+
+// Lookup finds an entry according to the given parameters, as for
+// Tree.Lookup: GTE finds the ceiling entry of the key and LTE the floor.  The
+// boolean reports whether the key itself was found; if there is no matching
+// entry the returned key is nil.
+func (m *OrderedMap) Lookup(lt c.LookupType, key c.Comparable) (c.Comparable, interface{}, bool) {
+	x, exact := m.tree.Lookup(lt, mapEntry{key: key})
+	e := entryOf(x)
+	return e.key, e.value, exact
+}
+
+// Keys opens a cursor positioned before the first key in the map.
+func (m *OrderedMap) Keys() c.Cursor {
+	return &keyCursor{m.First()}
+}
+
+// First opens a cursor positioned before the first entry in the map.
+func (m *OrderedMap) First() EntryCursor {
+	return &mapCursor{cursor: m.tree.First()}
+}
+
+// Last opens a cursor positioned after the last entry in the map.
+func (m *OrderedMap) Last() EntryCursor {
+	return &mapCursor{cursor: m.tree.Last()}
+}
+
+// GetCursor opens a cursor whose first entry will be the one that would have
+// been returned by an equivalent Lookup() call.
+func (m *OrderedMap) GetCursor(lt c.LookupType, key c.Comparable) (EntryCursor, bool) {
+	cur, exact := m.tree.GetCursor(lt, mapEntry{key: key})
+	return &mapCursor{cursor: cur}, exact
+}
+
+// HasNext checks for the availability of a next entry from the cursor.
+func (mc *mapCursor) HasNext() bool {
+	return mc.cursor.HasNext()
+}
+
+// HasPrev checks for the availability of a previous entry from the cursor.
+func (mc *mapCursor) HasPrev() bool {
+	return mc.cursor.HasPrev()
+}
+
+// Next retrieves the next entry from the cursor.
+func (mc *mapCursor) Next() (c.Comparable, interface{}) {
+	e := entryOf(mc.cursor.Next())
+	return e.key, e.value
+}
+
+// Prev retrieves the previous entry from the cursor.
+func (mc *mapCursor) Prev() (c.Comparable, interface{}) {
+	e := entryOf(mc.cursor.Prev())
+	return e.key, e.value
+}
+
+// keyCursor presents an EntryCursor as a Cursor over the keys.
+type keyCursor struct {
+	EntryCursor
+}
+
+func (kc *keyCursor) Next() c.Comparable {
+	k, _ := kc.EntryCursor.Next()
+	return k
+}
+
+func (kc *keyCursor) Prev() c.Comparable {
+	k, _ := kc.EntryCursor.Prev()
+	return k
+}
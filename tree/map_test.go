@@ -0,0 +1,86 @@
+package tree
+
+import (
+	"math/rand"
+	"testing"
+
+	c "github.com/dtromb/collections"
+)
+
+func TestOrderedMap(t *testing.T) {
+	maps := map[string]*OrderedMap{
+		"BTREE/3": {tree: NewBTree(3)},
+	}
+	// Every implementation must be able to back a map.
+	for impl := AVL_THREAD; impl.String() != "UNKNOWN"; impl++ {
+		maps[impl.String()] = NewOrderedMap(impl)
+	}
+	for name, m := range maps {
+		m := m
+		t.Run(name, func(t *testing.T) {
+			var N = 2000
+			model := make(map[int]int)
+			rnd := rand.New(rand.NewSource(7))
+			for i := 0; i < N*2; i++ {
+				k := rnd.Intn(N) * 2
+				old, replaced := m.Put(ComparableInt(k), i)
+				if mv, has := model[k]; has != replaced || (has && old != mv) {
+					t.Fatalf("Put(%d) returned %v,%v; expected %v,%v", k, old, replaced, mv, has)
+				}
+				model[k] = i
+			}
+			for i := 0; i < N/2; i++ {
+				k := rnd.Intn(N) * 2
+				v, found := m.Remove(ComparableInt(k))
+				if mv, has := model[k]; has != found || (has && v != mv) {
+					t.Fatalf("Remove(%d) returned %v,%v; expected %v,%v", k, v, found, mv, has)
+				}
+				delete(model, k)
+			}
+			if int(m.Size()) != len(model) {
+				t.Fatalf("size %d, expected %d", m.Size(), len(model))
+			}
+			for k := -1; k <= 2*N; k++ {
+				v, found := m.Get(ComparableInt(k))
+				if mv, has := model[k]; has != found || (has && v != mv) {
+					t.Fatalf("Get(%d) returned %v,%v; expected %v,%v", k, v, found, mv, has)
+				}
+				fk, fv, _ := m.Lookup(c.LTE, ComparableInt(k))
+				floor := k
+				for ; floor >= 0; floor-- {
+					if _, has := model[floor]; has {
+						break
+					}
+				}
+				if floor < 0 {
+					if fk != nil {
+						t.Fatalf("floor of %d is %v, expected none", k, fk)
+					}
+				} else if fk != ComparableInt(floor) || fv != model[floor] {
+					t.Fatalf("floor of %d is %v=%v, expected %d=%d", k, fk, fv, floor, model[floor])
+				}
+			}
+			var count int
+			var last c.Comparable
+			for cur := m.First(); cur.HasNext(); count++ {
+				k, v := cur.Next()
+				if last != nil && last.CompareTo(k) >= 0 {
+					t.Fatal("entries out of order")
+				}
+				if v != model[int(k.(ComparableInt))] {
+					t.Fatalf("cursor value for %v is %v", k, v)
+				}
+				last = k
+			}
+			if count != len(model) {
+				t.Fatalf("cursor returned %d entries, expected %d", count, len(model))
+			}
+			for cur := m.Last(); cur.HasPrev(); {
+				k, v := cur.Prev()
+				if v != model[int(k.(ComparableInt))] {
+					t.Fatalf("reverse cursor value for %v is %v", k, v)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,373 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// PersistentAvlTree is an immutable AVL Balanced Binary Tree.  Insert and
+// Delete leave the receiver untouched and return a new tree which shares all
+// but O(ln(n)) of its nodes with the original, so every version remains
+// valid and can be used as a cheap snapshot.  The zero value is an empty tree.
+//
+// Since nodes are shared between versions they cannot carry threads; cursors
+// keep the path from the root instead, and iteration is O(1) amortized.
+type PersistentAvlTree struct {
+	root *pavlNode
+}
+
+type pavlNode struct {
+	data   c.Comparable
+	height int8
+	size   uint
+	l, r   *pavlNode
+}
+
+type pavlCursor struct {
+	tree  *PersistentAvlTree
+	stack []*pavlNode
+	end   bool
+}
+
+func (n *pavlNode) count() uint {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *pavlNode) depth() int8 {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func pavlMake(data c.Comparable, l, r *pavlNode) *pavlNode {
+	h := l.depth()
+	if r.depth() > h {
+		h = r.depth()
+	}
+	return &pavlNode{
+		data:   data,
+		height: h + 1,
+		size:   l.count() + r.count() + 1,
+		l:      l,
+		r:      r,
+	}
+}
+
+// pavlBalance builds a node from data and subtrees whose heights differ by at
+// most two, rotating as needed to restore the AVL invariant.
+func pavlBalance(data c.Comparable, l, r *pavlNode) *pavlNode {
+	lh, rh := l.depth(), r.depth()
+	if lh > rh+1 {
+		if l.l.depth() >= l.r.depth() {
+			return pavlMake(l.data, l.l, pavlMake(data, l.r, r))
+		}
+		lr := l.r
+		return pavlMake(lr.data, pavlMake(l.data, l.l, lr.l), pavlMake(data, lr.r, r))
+	}
+	if rh > lh+1 {
+		if r.r.depth() >= r.l.depth() {
+			return pavlMake(r.data, pavlMake(data, l, r.l), r.r)
+		}
+		rl := r.l
+		return pavlMake(rl.data, pavlMake(data, l, rl.l), pavlMake(r.data, rl.r, r.r))
+	}
+	return pavlMake(data, l, r)
+}
+
+// BuildPersistentFromSortedSlice creates a tree holding the values of a slice
+// sorted in ascending order, as for BuildFromSortedSlice, in O(n) time.
+func BuildPersistentFromSortedSlice(vals []c.Comparable) *PersistentAvlTree {
+	var dedup []c.Comparable
+	for _, v := range vals {
+		if k := len(dedup); k > 0 {
+			r := dedup[k-1].CompareTo(v)
+			if r > 0 {
+				panic("BuildPersistentFromSortedSlice() input out of order")
+			}
+			if r == 0 {
+				dedup[k-1] = v
+				continue
+			}
+		}
+		dedup = append(dedup, v)
+	}
+	return &PersistentAvlTree{root: pavlBuild(dedup)}
+}
+
+func pavlBuild(vals []c.Comparable) *pavlNode {
+	if len(vals) == 0 {
+		return nil
+	}
+	mid := len(vals) / 2
+	return pavlMake(vals[mid], pavlBuild(vals[:mid]), pavlBuild(vals[mid+1:]))
+}
+
+// Size returns the number of elements in the tree.
+func (t *PersistentAvlTree) Size() uint {
+	return t.root.count()
+}
+
+func (t *PersistentAvlTree) Has(data c.Comparable) bool {
+	_, has := t.Lookup(c.LTE, data)
+	return has
+}
+
+// Lookup finds a value in the tree according to the given parameters.
+func (t *PersistentAvlTree) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	var lower, upper *pavlNode
+	var exact bool
+	cn := t.root
+	for cn != nil {
+		r := data.CompareTo(cn.data)
+		if r == 0 {
+			exact = true
+			switch lt {
+			case c.GT:
+				cn = cn.r
+			case c.LT:
+				cn = cn.l
+			default:
+				return cn.data, true
+			}
+			continue
+		}
+		if r < 0 {
+			upper = cn
+			cn = cn.l
+		} else {
+			lower = cn
+			cn = cn.r
+		}
+	}
+	if ascending(lt) {
+		lower = upper
+	}
+	if lower == nil || lt == c.EQ {
+		return nil, exact
+	}
+	return lower.data, exact
+}
+
+// Insert returns a tree with the value added, or replacing an equal value.
+// If a value was replaced it is returned as well; otherwise it is nil.
+func (t *PersistentAvlTree) Insert(data c.Comparable) (*PersistentAvlTree, c.Comparable) {
+	root, old := pavlInsert(t.root, data)
+	return &PersistentAvlTree{root: root}, old
+}
+
+func pavlInsert(n *pavlNode, data c.Comparable) (*pavlNode, c.Comparable) {
+	if n == nil {
+		return &pavlNode{data: data, height: 1, size: 1}, nil
+	}
+	r := data.CompareTo(n.data)
+	if r == 0 {
+		nn := *n
+		nn.data = data
+		return &nn, n.data
+	}
+	if r < 0 {
+		l, old := pavlInsert(n.l, data)
+		return pavlBalance(n.data, l, n.r), old
+	}
+	rn, old := pavlInsert(n.r, data)
+	return pavlBalance(n.data, n.l, rn), old
+}
+
+// Delete returns a tree without the given value.  If the argument is found,
+// the canonical value is returned along with boolean true; otherwise the
+// receiver itself is returned with (nil,false).
+func (t *PersistentAvlTree) Delete(data c.Comparable) (*PersistentAvlTree, c.Comparable, bool) {
+	root, old, found := pavlDelete(t.root, data)
+	if !found {
+		return t, nil, false
+	}
+	return &PersistentAvlTree{root: root}, old, true
+}
+
+func pavlDelete(n *pavlNode, data c.Comparable) (*pavlNode, c.Comparable, bool) {
+	if n == nil {
+		return nil, nil, false
+	}
+	r := data.CompareTo(n.data)
+	if r < 0 {
+		l, old, found := pavlDelete(n.l, data)
+		if !found {
+			return n, nil, false
+		}
+		return pavlBalance(n.data, l, n.r), old, true
+	}
+	if r > 0 {
+		rn, old, found := pavlDelete(n.r, data)
+		if !found {
+			return n, nil, false
+		}
+		return pavlBalance(n.data, n.l, rn), old, true
+	}
+	if n.l == nil {
+		return n.r, n.data, true
+	}
+	if n.r == nil {
+		return n.l, n.data, true
+	}
+	rn, min := pavlDeleteMin(n.r)
+	return pavlBalance(min, n.l, rn), n.data, true
+}
+
+func pavlDeleteMin(n *pavlNode) (*pavlNode, c.Comparable) {
+	if n.l == nil {
+		return n.r, n.data
+	}
+	l, min := pavlDeleteMin(n.l)
+	return pavlBalance(n.data, l, n.r), min
+}
+
+// First opens a cursor positioned before the first value in the tree.
+func (t *PersistentAvlTree) First() c.Cursor {
+	return &pavlCursor{tree: t}
+}
+
+// Last opens a cursor positioned after the last value in the tree.
+func (t *PersistentAvlTree) Last() c.Cursor {
+	return &pavlCursor{tree: t, end: true}
+}
+
+// GetCursor opens a cursor whose first value will be the value that would have
+// been returned by an equivalent Lookup() call.  The iterator is bidirectional
+// and can range past the start of the search.  Since the tree is immutable,
+// the cursor is unaffected by later versions.
+func (t *PersistentAvlTree) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	tc := &pavlCursor{tree: t}
+	var exact bool
+	cn := t.root
+	for cn != nil {
+		tc.stack = append(tc.stack, cn)
+		r := data.CompareTo(cn.data)
+		if r == 0 {
+			exact = true
+			break
+		}
+		if r < 0 {
+			cn = cn.l
+		} else {
+			cn = cn.r
+		}
+	}
+	if exact {
+		switch lt {
+		case c.GT:
+			tc.successor()
+		case c.LT:
+			tc.predecessor()
+		}
+	} else if len(tc.stack) > 0 {
+		// The search ended at a node adjacent to the target position.
+		r := data.CompareTo(tc.stack[len(tc.stack)-1].data)
+		if r > 0 && ascending(lt) {
+			tc.successor()
+		} else if r < 0 && !ascending(lt) {
+			tc.predecessor()
+		}
+	}
+	if len(tc.stack) == 0 {
+		tc.end = ascending(lt)
+	}
+	return tc, exact
+}
+
+// successor moves the top of the stack to its in-order successor, emptying
+// the stack if there is none.
+func (c *pavlCursor) successor() {
+	n := c.stack[len(c.stack)-1]
+	if n.r != nil {
+		for n = n.r; n != nil; n = n.l {
+			c.stack = append(c.stack, n)
+		}
+		return
+	}
+	c.stack = c.stack[:len(c.stack)-1]
+	for len(c.stack) > 0 && c.stack[len(c.stack)-1].r == n {
+		n = c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+}
+
+// predecessor moves the top of the stack to its in-order predecessor,
+// emptying the stack if there is none.
+func (c *pavlCursor) predecessor() {
+	n := c.stack[len(c.stack)-1]
+	if n.l != nil {
+		for n = n.l; n != nil; n = n.r {
+			c.stack = append(c.stack, n)
+		}
+		return
+	}
+	c.stack = c.stack[:len(c.stack)-1]
+	for len(c.stack) > 0 && c.stack[len(c.stack)-1].l == n {
+		n = c.stack[len(c.stack)-1]
+		c.stack = c.stack[:len(c.stack)-1]
+	}
+}
+
+// HasNext checks for the availability of a next data value from the cursor.
+func (c *pavlCursor) HasNext() bool {
+	return len(c.stack) > 0 || (!c.end && c.tree.root != nil)
+}
+
+// HasPrev checks for the availability of a previous data value from the cursor.
+func (c *pavlCursor) HasPrev() bool {
+	if len(c.stack) == 0 {
+		return c.end && c.tree.root != nil
+	}
+	if c.stack[len(c.stack)-1].l != nil {
+		return true
+	}
+	for i := len(c.stack) - 1; i > 0; i-- {
+		if c.stack[i-1].r == c.stack[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Next retrieves the next value from the cursor.
+func (c *pavlCursor) Next() c.Comparable {
+	if len(c.stack) == 0 {
+		if c.end {
+			return nil
+		}
+		for n := c.tree.root; n != nil; n = n.l {
+			c.stack = append(c.stack, n)
+		}
+		if len(c.stack) == 0 {
+			return nil
+		}
+	}
+	val := c.stack[len(c.stack)-1].data
+	c.successor()
+	if len(c.stack) == 0 {
+		c.end = true
+	}
+	return val
+}
+
+// Prev retrieves the previous value from the cursor.  As with the AvlTree
+// cursor, switching direction returns the value most recently returned.
+func (c *pavlCursor) Prev() c.Comparable {
+	if len(c.stack) == 0 {
+		if c.end && c.tree.root != nil {
+			for n := c.tree.root; n != nil; n = n.r {
+				c.stack = append(c.stack, n)
+			}
+			c.end = false
+			return c.stack[len(c.stack)-1].data
+		}
+		return nil
+	}
+	c.predecessor()
+	if len(c.stack) == 0 {
+		c.end = false
+		return nil
+	}
+	return c.stack[len(c.stack)-1].data
+}
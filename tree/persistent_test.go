@@ -0,0 +1,97 @@
+package tree
+
+import (
+	"math/rand"
+	"testing"
+
+	c "github.com/dtromb/collections"
+)
+
+// persistentAdapter presents a PersistentAvlTree as a mutable Tree so that it
+// can be run through the conformance suite.
+type persistentAdapter struct {
+	t *PersistentAvlTree
+}
+
+func (pa *persistentAdapter) Size() uint                 { return pa.t.Size() }
+func (pa *persistentAdapter) Has(data c.Comparable) bool { return pa.t.Has(data) }
+func (pa *persistentAdapter) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	return pa.t.Lookup(lt, data)
+}
+func (pa *persistentAdapter) Insert(data c.Comparable) c.Comparable {
+	var old c.Comparable
+	pa.t, old = pa.t.Insert(data)
+	return old
+}
+func (pa *persistentAdapter) Delete(data c.Comparable) (c.Comparable, bool) {
+	var old c.Comparable
+	var found bool
+	pa.t, old, found = pa.t.Delete(data)
+	return old, found
+}
+func (pa *persistentAdapter) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	return pa.t.GetCursor(lt, data)
+}
+func (pa *persistentAdapter) First() c.Cursor { return pa.t.First() }
+func (pa *persistentAdapter) Last() c.Cursor  { return pa.t.Last() }
+
+func checkPavlNode(n *pavlNode) {
+	if n == nil {
+		return
+	}
+	checkPavlNode(n.l)
+	checkPavlNode(n.r)
+	if n.l != nil && n.data.CompareTo(n.l.data) <= 0 {
+		panic("left child order violation")
+	}
+	if n.r != nil && n.data.CompareTo(n.r.data) >= 0 {
+		panic("right child order violation")
+	}
+	lh, rh := n.l.depth(), n.r.depth()
+	if lh-rh < -1 || lh-rh > 1 {
+		panic("balance out of range")
+	}
+	if pavlMake(n.data, n.l, n.r).height != n.height {
+		panic("incorrect height record")
+	}
+	if n.size != n.l.count()+n.r.count()+1 {
+		panic("incorrect subtree size record")
+	}
+}
+
+func TestPersistentVersions(t *testing.T) {
+	var N = 1000
+	versions := []*PersistentAvlTree{{}}
+	rnd := rand.New(rand.NewSource(3))
+	perm := rnd.Perm(N)
+	for _, i := range perm {
+		nt, _ := versions[len(versions)-1].Insert(ComparableInt(i))
+		versions = append(versions, nt)
+	}
+	for i := 0; i < N/2; i++ {
+		nt, _, found := versions[len(versions)-1].Delete(ComparableInt(perm[i]))
+		if !found {
+			t.Fatalf("Delete(%d) did not find value", perm[i])
+		}
+		versions = append(versions, nt)
+	}
+	// Every version must still hold exactly the values it was created with.
+	for v, pt := range versions {
+		checkPavlNode(pt.root)
+		present := make(map[int]bool)
+		for j := 0; j < v && j < N; j++ {
+			present[perm[j]] = true
+		}
+		for j := 0; j < v-N; j++ {
+			delete(present, perm[j])
+		}
+		if int(pt.Size()) != len(present) {
+			t.Fatalf("version %d has size %d, expected %d", v, pt.Size(), len(present))
+		}
+		for x := range present {
+			if !pt.Has(ComparableInt(x)) {
+				t.Fatalf("version %d lost value %d", v, x)
+			}
+		}
+	}
+}
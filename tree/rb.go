@@ -0,0 +1,385 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// RbTree is an implementation of a Red-Black Balanced Binary Tree, with threads.
+// O(1) iteration and O(ln(n)) time for other operations.  It performs at most
+// two rotations per insert and three per delete, which favors update-heavy
+// workloads at the cost of a somewhat deeper tree than AvlTree.
+type RbTree struct {
+	size uint
+	root *rbNode
+	head *rbNode
+	tail *rbNode
+}
+
+type rbNode struct {
+	data              c.Comparable
+	red               bool
+	l, r, p, nxt, prv *rbNode
+}
+
+func (n *rbNode) dataRef() *c.Comparable { return &n.data }
+func (n *rbNode) succ() *rbNode          { return n.nxt }
+func (n *rbNode) pred() *rbNode          { return n.prv }
+
+func (t *RbTree) ends() (*rbNode, *rbNode) {
+	return t.head, t.tail
+}
+
+func isRed(n *rbNode) bool {
+	return n != nil && n.red
+}
+
+func (t *RbTree) Has(data c.Comparable) bool {
+	_, has := t.Lookup(c.LTE, data)
+	return has
+}
+
+// Lookup finds a value in the tree according to the given parameters.
+func (t *RbTree) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	n, exact := t.lookupNode(lt, data)
+	if n == nil || (lt == c.EQ && !exact) {
+		return nil, exact
+	}
+	return n.data, exact
+}
+
+func (t *RbTree) lookupNode(lt c.LookupType, data c.Comparable) (*rbNode, bool) {
+	if t.root == nil {
+		return nil, false
+	}
+	cn := t.root
+	for {
+		r := data.CompareTo(cn.data)
+		if r == 0 {
+			switch lt {
+			case c.LT:
+				return cn.prv, true
+			case c.GT:
+				return cn.nxt, true
+			}
+			return cn, true
+		}
+		if r < 0 {
+			if cn.l == nil {
+				if ascending(lt) {
+					return cn, false
+				}
+				return cn.prv, false
+			}
+			cn = cn.l
+		} else {
+			if cn.r == nil {
+				if !ascending(lt) {
+					return cn, false
+				}
+				return cn.nxt, false
+			}
+			cn = cn.r
+		}
+	}
+}
+
+// Size returns the number of elements in the tree.
+func (t *RbTree) Size() uint {
+	return t.size
+}
+
+func (t *RbTree) rotateLeft(x *rbNode) {
+	y := x.r
+	x.r = y.l
+	if y.l != nil {
+		y.l.p = x
+	}
+	y.p = x.p
+	if x.p == nil {
+		t.root = y
+	} else if x.p.l == x {
+		x.p.l = y
+	} else {
+		x.p.r = y
+	}
+	y.l = x
+	x.p = y
+}
+
+func (t *RbTree) rotateRight(x *rbNode) {
+	y := x.l
+	x.l = y.r
+	if y.r != nil {
+		y.r.p = x
+	}
+	y.p = x.p
+	if x.p == nil {
+		t.root = y
+	} else if x.p.r == x {
+		x.p.r = y
+	} else {
+		x.p.l = y
+	}
+	y.r = x
+	x.p = y
+}
+
+// transplant replaces the subtree rooted at u with the one rooted at v.
+func (t *RbTree) transplant(u, v *rbNode) {
+	if u.p == nil {
+		t.root = v
+	} else if u.p.l == u {
+		u.p.l = v
+	} else {
+		u.p.r = v
+	}
+	if v != nil {
+		v.p = u.p
+	}
+}
+
+// Insert adds or replaces a value in the tree.  If there is already an equal value,
+// it is replaced and the old value returned.  Otherwise, nil is returned.
+func (t *RbTree) Insert(data c.Comparable) c.Comparable {
+	if t.root == nil {
+		t.root = &rbNode{
+			data: data,
+		}
+		t.size++
+		t.head = t.root
+		t.tail = t.root
+		return nil
+	}
+	cn := t.root
+	var r int8
+	for {
+		r = data.CompareTo(cn.data)
+		if r == 0 {
+			break
+		}
+		if r < 0 {
+			if cn.l == nil {
+				break
+			}
+			cn = cn.l
+		} else {
+			if cn.r == nil {
+				break
+			}
+			cn = cn.r
+		}
+	}
+	if r == 0 {
+		oldData := cn.data
+		cn.data = data
+		return oldData
+	}
+	nn := &rbNode{
+		data: data,
+		red:  true,
+		p:    cn,
+	}
+	if r < 0 {
+		nn.prv = cn.prv
+		nn.nxt = cn
+		if cn.prv != nil {
+			cn.prv.nxt = nn
+		} else {
+			t.head = nn
+		}
+		cn.prv = nn
+		cn.l = nn
+	} else {
+		nn.nxt = cn.nxt
+		nn.prv = cn
+		if cn.nxt != nil {
+			cn.nxt.prv = nn
+		} else {
+			t.tail = nn
+		}
+		cn.nxt = nn
+		cn.r = nn
+	}
+	t.size++
+	t.insertFixup(nn)
+	return nil
+}
+
+func (t *RbTree) insertFixup(n *rbNode) {
+	for isRed(n.p) {
+		// A red parent is never the root, so the grandparent exists.
+		gp := n.p.p
+		if n.p == gp.l {
+			u := gp.r
+			if isRed(u) {
+				n.p.red = false
+				u.red = false
+				gp.red = true
+				n = gp
+				continue
+			}
+			if n == n.p.r {
+				n = n.p
+				t.rotateLeft(n)
+			}
+			n.p.red = false
+			gp.red = true
+			t.rotateRight(gp)
+		} else {
+			u := gp.l
+			if isRed(u) {
+				n.p.red = false
+				u.red = false
+				gp.red = true
+				n = gp
+				continue
+			}
+			if n == n.p.l {
+				n = n.p
+				t.rotateRight(n)
+			}
+			n.p.red = false
+			gp.red = true
+			t.rotateLeft(gp)
+		}
+	}
+	t.root.red = false
+}
+
+// Delete removes an element from the tree.  If the argument is found, the
+// canonical value from the tree is returned along with boolean true.  If not,
+// the pair (nil,false) is returned.
+func (t *RbTree) Delete(data c.Comparable) (c.Comparable, bool) {
+	z, exact := t.lookupNode(c.GTE, data)
+	if !exact {
+		return nil, false
+	}
+	t.deleteNode(z)
+	return z.data, true
+}
+
+// deleteNode unlinks z from the tree.  Nodes are relinked rather than having
+// their data swapped, so that cursors holding other nodes remain valid.
+func (t *RbTree) deleteNode(z *rbNode) {
+	var x, xp *rbNode
+	removedRed := z.red
+	if z.l == nil {
+		x, xp = z.r, z.p
+		t.transplant(z, z.r)
+	} else if z.r == nil {
+		x, xp = z.l, z.p
+		t.transplant(z, z.l)
+	} else {
+		y := z.nxt // leftmost node of z.r
+		removedRed = y.red
+		x = y.r
+		if y.p == z {
+			xp = y
+		} else {
+			xp = y.p
+			t.transplant(y, y.r)
+			y.r = z.r
+			y.r.p = y
+		}
+		t.transplant(z, y)
+		y.l = z.l
+		y.l.p = y
+		y.red = z.red
+	}
+
+	if z.prv != nil {
+		z.prv.nxt = z.nxt
+	} else {
+		t.head = z.nxt
+	}
+	if z.nxt != nil {
+		z.nxt.prv = z.prv
+	} else {
+		t.tail = z.prv
+	}
+	z.nxt = nil
+	z.prv = nil
+	t.size--
+
+	if !removedRed {
+		t.deleteFixup(x, xp)
+	}
+}
+
+// deleteFixup restores the black-height invariant after the removal of a black
+// node.  x (possibly nil) carries the extra black and xp is its parent.
+func (t *RbTree) deleteFixup(x, xp *rbNode) {
+	for x != t.root && !isRed(x) {
+		if x == xp.l {
+			w := xp.r
+			if w.red {
+				w.red = false
+				xp.red = true
+				t.rotateLeft(xp)
+				w = xp.r
+			}
+			if !isRed(w.l) && !isRed(w.r) {
+				w.red = true
+				x = xp
+				xp = x.p
+				continue
+			}
+			if !isRed(w.r) {
+				w.l.red = false
+				w.red = true
+				t.rotateRight(w)
+				w = xp.r
+			}
+			w.red = xp.red
+			xp.red = false
+			w.r.red = false
+			t.rotateLeft(xp)
+			x = t.root
+		} else {
+			w := xp.l
+			if w.red {
+				w.red = false
+				xp.red = true
+				t.rotateRight(xp)
+				w = xp.l
+			}
+			if !isRed(w.l) && !isRed(w.r) {
+				w.red = true
+				x = xp
+				xp = x.p
+				continue
+			}
+			if !isRed(w.l) {
+				w.r.red = false
+				w.red = true
+				t.rotateLeft(w)
+				w = xp.l
+			}
+			w.red = xp.red
+			xp.red = false
+			w.l.red = false
+			t.rotateRight(xp)
+			x = t.root
+		}
+	}
+	if x != nil {
+		x.red = false
+	}
+}
+
+// First opens a cursor positioned before the first value in the tree.
+func (t *RbTree) First() c.Cursor {
+	return newThreadCursor(t, t.head, false, t.deleteNode)
+}
+
+// Last opens a cursor positioned after the last value in the tree.
+func (t *RbTree) Last() c.Cursor {
+	return newThreadCursor(t, nil, true, t.deleteNode)
+}
+
+// GetCursor opens a cursor whose first value will be the value that would have
+// been returned by an equivalent Lookup() call.  The iterator is bidirectional
+// and can range past the start of the search.  It is not fail-fast - changes
+// in the tree will change its behavior.
+func (t *RbTree) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	n, exact := t.lookupNode(lt, data)
+	return newThreadCursor(t, n, n == nil && ascending(lt), t.deleteNode), exact
+}
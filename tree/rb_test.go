@@ -0,0 +1,67 @@
+package tree
+
+// checkRbNode verifies the structure, threading and coloring of the subtree
+// rooted at n, returning its black height.
+func checkRbNode(t *RbTree, n *rbNode) int {
+	if n == nil {
+		return 1
+	}
+	if n.l != nil && n.l.p != n {
+		panic("left child's parent is not self")
+	}
+	if n.r != nil && n.r.p != n {
+		panic("right child's parent is not self")
+	}
+	if n.p == nil && n != t.root {
+		panic("node without parent is not tree root")
+	}
+	if n.l != nil && n.data.CompareTo(n.l.data) <= 0 {
+		panic("left child order violation")
+	}
+	if n.r != nil && n.data.CompareTo(n.r.data) >= 0 {
+		panic("right child order violation")
+	}
+	if n.prv != nil && n.prv.nxt != n {
+		panic("node is not linked as predecessor's successor")
+	}
+	if n.nxt != nil && n.nxt.prv != n {
+		panic("node is not linked as sucessor's predecessor")
+	}
+	if n.red && (isRed(n.l) || isRed(n.r)) {
+		panic("red node has red child")
+	}
+	lh := checkRbNode(t, n.l)
+	rh := checkRbNode(t, n.r)
+	if lh != rh {
+		panic("unequal black heights")
+	}
+	if !n.red {
+		lh++
+	}
+	return lh
+}
+
+func checkRbTree(t *RbTree) {
+	if isRed(t.root) {
+		panic("root is red")
+	}
+	checkRbNode(t, t.root)
+	var count uint
+	var last *rbNode
+	for n := t.head; n != nil; n = n.nxt {
+		if n.prv != last {
+			panic("incorrect predecessor link")
+		}
+		if last != nil && last.data.CompareTo(n.data) >= 0 {
+			panic("successor order violation")
+		}
+		last = n
+		count++
+	}
+	if last != t.tail {
+		panic("tail is not the last threaded node")
+	}
+	if count != t.Size() {
+		panic("incorrect size in tree")
+	}
+}
@@ -0,0 +1,210 @@
+package tree
+
+import (
+	"math/bits"
+	"math/rand/v2"
+
+	c "github.com/dtromb/collections"
+)
+
+// maxSkipLevel is the number of levels in a skip list, enough for 2^32
+// elements at the expected density.
+const maxSkipLevel = 32
+
+// skipLevel returns the number of levels for a new skip list node, given a
+// random word: k with probability 2^-k, up to maxSkipLevel.
+func skipLevel(u uint64) int {
+	return min(bits.TrailingZeros64(u)+1, maxSkipLevel)
+}
+
+// SkipList is a Tree implemented as a skip list, with a backward link from
+// each node to its predecessor at the bottom level for bidirectional cursors.
+// It takes expected O(ln(n)) time for lookups and changes, and O(1) for
+// iteration.  The shape of the list depends only on the values inserted and
+// the random source it was created with.
+type SkipList struct {
+	head  *slNode
+	tail  *slNode
+	level int
+	size  uint
+	src   rand.Source
+}
+
+type slNode struct {
+	data c.Comparable
+	next []*slNode
+	prv  *slNode
+}
+
+// NewSkipList creates an empty SkipList which draws node levels from src, or
+// from the global generator of math/rand/v2 if src is nil.  Lists built from
+// sources in the same state by the same sequence of changes have the same
+// shape.
+func NewSkipList(src rand.Source) *SkipList {
+	return &SkipList{head: &slNode{next: make([]*slNode, maxSkipLevel)}, src: src}
+}
+
+// randomLevel returns the number of levels for a new node.
+func (sl *SkipList) randomLevel() int {
+	if sl.src == nil {
+		return skipLevel(rand.Uint64())
+	}
+	return skipLevel(sl.src.Uint64())
+}
+
+// first returns the first node in the list, or nil.
+func (sl *SkipList) first() *slNode {
+	return sl.head.next[0]
+}
+
+func (n *slNode) dataRef() *c.Comparable { return &n.data }
+func (n *slNode) succ() *slNode          { return n.next[0] }
+func (n *slNode) pred() *slNode          { return n.prv }
+
+func (sl *SkipList) ends() (*slNode, *slNode) {
+	return sl.first(), sl.tail
+}
+
+// search fills preds with the last node before x at each level in use, and
+// returns the first node holding a value >= x, or nil.
+func (sl *SkipList) search(x c.Comparable, preds []*slNode) *slNode {
+	pred := sl.head
+	for l := sl.level - 1; l >= 0; l-- {
+		for pred.next[l] != nil && x.CompareTo(pred.next[l].data) > 0 {
+			pred = pred.next[l]
+		}
+		preds[l] = pred
+	}
+	return pred.next[0]
+}
+
+// before returns a predecessor found by search as a node of the list, or nil
+// if it is the head.
+func (sl *SkipList) before(pred *slNode) *slNode {
+	if pred == sl.head {
+		return nil
+	}
+	return pred
+}
+
+func (sl *SkipList) lookupNode(lt c.LookupType, data c.Comparable) (*slNode, bool) {
+	var preds [maxSkipLevel]*slNode
+	preds[0] = sl.head
+	n := sl.search(data, preds[:])
+	exact := n != nil && data.CompareTo(n.data) == 0
+	switch lt {
+	case c.GT:
+		if exact {
+			n = n.next[0]
+		}
+	case c.LTE:
+		if !exact {
+			n = sl.before(preds[0])
+		}
+	case c.LT:
+		n = sl.before(preds[0])
+	}
+	return n, exact
+}
+
+// Size returns the number of elements in the list.
+func (sl *SkipList) Size() uint {
+	return sl.size
+}
+
+func (sl *SkipList) Has(data c.Comparable) bool {
+	_, has := sl.lookupNode(c.EQ, data)
+	return has
+}
+
+// Lookup finds a value in the list according to the given parameters.
+func (sl *SkipList) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	n, exact := sl.lookupNode(lt, data)
+	if n == nil || (lt == c.EQ && !exact) {
+		return nil, exact
+	}
+	return n.data, exact
+}
+
+// Insert adds a value to the list, replacing and returning any equal value.
+func (sl *SkipList) Insert(data c.Comparable) c.Comparable {
+	var preds [maxSkipLevel]*slNode
+	n := sl.search(data, preds[:])
+	if n != nil && data.CompareTo(n.data) == 0 {
+		old := n.data
+		n.data = data
+		return old
+	}
+	level := sl.randomLevel()
+	for ; sl.level < level; sl.level++ {
+		preds[sl.level] = sl.head
+	}
+	nn := &slNode{data: data, next: make([]*slNode, level)}
+	for l := 0; l < level; l++ {
+		nn.next[l] = preds[l].next[l]
+		preds[l].next[l] = nn
+	}
+	nn.prv = sl.before(preds[0])
+	if nn.next[0] != nil {
+		nn.next[0].prv = nn
+	} else {
+		sl.tail = nn
+	}
+	sl.size++
+	return nil
+}
+
+// Delete removes an element from the list.  If the argument is found, the
+// value from the list is returned along with boolean true.  If not, nil and
+// false is returned.
+func (sl *SkipList) Delete(data c.Comparable) (c.Comparable, bool) {
+	var preds [maxSkipLevel]*slNode
+	n := sl.search(data, preds[:])
+	if n == nil || data.CompareTo(n.data) != 0 {
+		return nil, false
+	}
+	sl.deleteNode(n, preds[:])
+	return n.data, true
+}
+
+// unlink removes n from the list.
+func (sl *SkipList) unlink(n *slNode) {
+	var preds [maxSkipLevel]*slNode
+	sl.search(n.data, preds[:])
+	sl.deleteNode(n, preds[:])
+}
+
+// deleteNode unlinks n from the list, given the predecessors of its value.
+func (sl *SkipList) deleteNode(n *slNode, preds []*slNode) {
+	for l := range n.next {
+		preds[l].next[l] = n.next[l]
+	}
+	if n.next[0] != nil {
+		n.next[0].prv = n.prv
+	} else {
+		sl.tail = n.prv
+	}
+	for sl.level > 0 && sl.head.next[sl.level-1] == nil {
+		sl.level--
+	}
+	n.next[0], n.prv = nil, nil
+	sl.size--
+}
+
+// First opens a cursor positioned before the first value in the list.
+func (sl *SkipList) First() c.Cursor {
+	return newThreadCursor(sl, sl.first(), false, sl.unlink)
+}
+
+// Last opens a cursor positioned after the last value in the list.
+func (sl *SkipList) Last() c.Cursor {
+	return newThreadCursor(sl, nil, true, sl.unlink)
+}
+
+// GetCursor opens a cursor whose first value will be the value that would have
+// been returned by an equivalent Lookup() call.  The cursor is bidirectional
+// and can range past the start of the search.
+func (sl *SkipList) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	n, exact := sl.lookupNode(lt, data)
+	return newThreadCursor(sl, n, n == nil && ascending(lt), sl.unlink), exact
+}
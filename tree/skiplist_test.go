@@ -0,0 +1,95 @@
+package tree
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// checkSkipList panics unless each level of the list is sorted and a sublist
+// of the one below, the backward links mirror the bottom level, and the
+// level, tail and size agree with the nodes.
+func checkSkipList(sl *SkipList) {
+	for l := 0; l < maxSkipLevel; l++ {
+		if (l < sl.level) != (sl.head.next[l] != nil) {
+			panic(fmt.Sprintf("level %d in use disagrees with list level %d", l, sl.level))
+		}
+		var prev *slNode
+		for n := sl.head.next[l]; n != nil; n = n.next[l] {
+			if len(n.next) <= l {
+				panic("node linked above its top level")
+			}
+			if prev != nil && prev.data.CompareTo(n.data) >= 0 {
+				panic("level out of order")
+			}
+			if l > 0 {
+				found := false
+				for m := prev; m != n && !found; {
+					if m == nil {
+						m = sl.head.next[l-1]
+					} else {
+						m = m.next[l-1]
+					}
+					found = m == n
+				}
+				if !found {
+					panic("level is not a sublist of the one below")
+				}
+			}
+			prev = n
+		}
+	}
+	var n uint
+	var prev *slNode
+	for cn := sl.head.next[0]; cn != nil; cn = cn.next[0] {
+		if cn.prv != prev {
+			panic("backward link does not match")
+		}
+		prev = cn
+		n++
+	}
+	if prev != sl.tail || n != sl.size {
+		panic("tail or size does not match")
+	}
+}
+
+// shape returns the levels of the nodes of a list in order.
+func shape(sl *SkipList) []int {
+	var r []int
+	for n := sl.head.next[0]; n != nil; n = n.next[0] {
+		r = append(r, len(n.next))
+	}
+	return r
+}
+
+func TestSkipListSource(t *testing.T) {
+	build := func(seed uint64) *SkipList {
+		sl := NewSkipList(rand.NewPCG(seed, 0))
+		rnd := rand.New(rand.NewPCG(7, 7))
+		for i := 0; i < 2000; i++ {
+			x := ComparableInt(rnd.IntN(1000))
+			if rnd.IntN(3) == 0 {
+				sl.Delete(x)
+			} else {
+				sl.Insert(x)
+			}
+		}
+		checkSkipList(sl)
+		return sl
+	}
+	a, b, other := build(1), build(1), build(2)
+	if fmt.Sprint(shape(a)) != fmt.Sprint(shape(b)) {
+		t.Error("lists built from equal sources have different shapes")
+	}
+	if fmt.Sprint(shape(a)) == fmt.Sprint(shape(other)) {
+		t.Error("lists built from different sources have the same shape")
+	}
+	var levels [maxSkipLevel + 1]int
+	for _, l := range shape(a) {
+		levels[l]++
+	}
+	// About half the nodes have one level and a quarter two.
+	if n := len(shape(a)); levels[1] < n/3 || levels[1] > 2*n/3 || levels[2] < n/8 || levels[2] > n/2 {
+		t.Errorf("unlikely distribution of levels %v over %d nodes", levels[:8], n)
+	}
+}
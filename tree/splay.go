@@ -0,0 +1,130 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// SplayTree is a self-adjusting binary search tree, with threads.  Each
+// operation moves the node it reaches to the root, so recently used values
+// are found quickly: a sequence of operations takes O(ln(n)) amortized time
+// each, and much less when accesses are skewed toward a small set of values.
+// Since lookups restructure the tree, they are modifications for the purpose
+// of concurrent use.
+type SplayTree struct {
+	bsTree
+}
+
+// NewSplayTree creates an empty SplayTree.
+func NewSplayTree() *SplayTree {
+	return &SplayTree{}
+}
+
+// splay moves x to the root by rotations.
+func (t *SplayTree) splay(x *bsNode) {
+	for x.p != nil {
+		p := x.p
+		g := p.p
+		switch {
+		case g == nil:
+			t.rotateUp(x)
+		case (g.l == p) == (p.l == x):
+			t.rotateUp(p)
+			t.rotateUp(x)
+		default:
+			t.rotateUp(x)
+			t.rotateUp(x)
+		}
+	}
+}
+
+// access finds x as locate does, splaying the node reached.
+func (t *SplayTree) access(x c.Comparable) (*bsNode, int8) {
+	n, r := t.locate(x)
+	if n != nil {
+		t.splay(n)
+	}
+	return n, r
+}
+
+func (t *SplayTree) Has(data c.Comparable) bool {
+	n, r := t.access(data)
+	return n != nil && r == 0
+}
+
+// Lookup finds a value in the tree according to the given parameters.
+func (t *SplayTree) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	n, r := t.access(data)
+	exact := n != nil && r == 0
+	if n = neighbor(lt, n, r); n == nil || (lt == c.EQ && !exact) {
+		return nil, exact
+	}
+	return n.data, exact
+}
+
+// Insert adds a value to the tree, replacing and returning any equal value.
+func (t *SplayTree) Insert(data c.Comparable) c.Comparable {
+	n, r := t.locate(data)
+	if n != nil && r == 0 {
+		old := n.data
+		n.data = data
+		t.splay(n)
+		return old
+	}
+	nn := &bsNode{data: data}
+	t.attach(nn, n, r)
+	t.splay(nn)
+	return nil
+}
+
+// Delete removes an element from the tree.  If the argument is found, the
+// value from the tree is returned along with boolean true.  If not, nil and
+// false is returned.
+func (t *SplayTree) Delete(data c.Comparable) (c.Comparable, bool) {
+	n, r := t.access(data)
+	if n == nil || r != 0 {
+		return nil, false
+	}
+	t.deleteNode(n)
+	return n.data, true
+}
+
+// deleteNode splays n to the root and removes it, joining its subtrees under
+// its predecessor.
+func (t *SplayTree) deleteNode(n *bsNode) {
+	t.splay(n)
+	l, r := n.l, n.r
+	if l == nil {
+		t.replace(n, r)
+	} else {
+		// Splay the predecessor to the top of the left subtree, where it has
+		// no right child, and hang the right subtree there.
+		l.p = nil
+		t.root = l
+		pn := n.prv
+		t.splay(pn)
+		pn.r = r
+		if r != nil {
+			r.p = pn
+		}
+	}
+	t.unthread(n)
+}
+
+// First opens a cursor positioned before the first value in the tree.
+func (t *SplayTree) First() c.Cursor {
+	return t.cursor(t.head, false, t.deleteNode)
+}
+
+// Last opens a cursor positioned after the last value in the tree.
+func (t *SplayTree) Last() c.Cursor {
+	return t.cursor(nil, true, t.deleteNode)
+}
+
+// GetCursor opens a cursor whose first value will be the value that would have
+// been returned by an equivalent Lookup() call.  The cursor is bidirectional
+// and can range past the start of the search.  Moving the cursor does not
+// splay the tree.
+func (t *SplayTree) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	n, r := t.access(data)
+	exact := n != nil && r == 0
+	n = neighbor(lt, n, r)
+	return t.cursor(n, n == nil && ascending(lt), t.deleteNode), exact
+}
@@ -0,0 +1,117 @@
+package tree
+
+import c "github.com/dtromb/collections"
+
+// threadedNode is a node of a tree or list which is threaded to its successor
+// and predecessor, as in RbTree, SkipList and the self-adjusting trees.
+type threadedNode[N comparable] interface {
+	comparable
+	dataRef() *c.Comparable
+	succ() N
+	pred() N
+}
+
+// threadedList is the container of threaded nodes, holding the ends of the
+// thread.
+type threadedList[N any] interface {
+	Size() uint
+	ends() (head, tail N)
+}
+
+// threadCursor is a cursor which follows the thread of a threadedList; del
+// unlinks a node from the list in the manner of the implementation.  The
+// cursor survives any restructuring which leaves the thread intact.
+type threadCursor[N threadedNode[N]] struct {
+	list     threadedList[N]
+	del      func(n N)
+	nextNode N
+	last     N
+	end      bool
+}
+
+func newThreadCursor[N threadedNode[N]](list threadedList[N], n N, end bool, del func(n N)) *threadCursor[N] {
+	return &threadCursor[N]{list: list, del: del, nextNode: n, end: end}
+}
+
+// HasNext checks for the availability of a next data value from the cursor.
+func (tc *threadCursor[N]) HasNext() bool {
+	var none N
+	return tc.nextNode != none || !tc.end && tc.list.Size() > 0
+}
+
+// HasPrev checks for the availability of a previous data value from the cursor.
+func (tc *threadCursor[N]) HasPrev() bool {
+	var none N
+	return tc.nextNode != none && tc.nextNode.pred() != none ||
+		tc.nextNode == none && tc.end && tc.list.Size() > 0
+}
+
+// Next retrieves the next value from the cursor.
+func (tc *threadCursor[N]) Next() c.Comparable {
+	var none N
+	if tc.nextNode == none {
+		if tc.end {
+			tc.last = none
+			return nil
+		}
+		tc.nextNode, _ = tc.list.ends()
+	}
+	tc.last = tc.nextNode
+	if tc.nextNode == none {
+		return nil
+	}
+	val := *tc.nextNode.dataRef()
+	tc.nextNode = tc.nextNode.succ()
+	tc.end = tc.nextNode == none
+	return val
+}
+
+// Prev retrieves the previous value from the cursor.  As with the AvlTree
+// cursor, switching direction returns the value most recently returned.
+func (tc *threadCursor[N]) Prev() c.Comparable {
+	var none N
+	if tc.nextNode == none {
+		if _, tail := tc.list.ends(); tc.end && tail != none {
+			tc.nextNode = tail
+			tc.end = false
+			tc.last = tc.nextNode
+			return *tc.nextNode.dataRef()
+		}
+		tc.last = none
+		return nil
+	}
+	tc.nextNode = tc.nextNode.pred()
+	tc.last = tc.nextNode
+	if tc.nextNode == none {
+		return nil
+	}
+	return *tc.nextNode.dataRef()
+}
+
+// Remove deletes the value most recently returned from the list, leaving the
+// cursor valid.
+func (tc *threadCursor[N]) Remove() {
+	var none N
+	if tc.last == none {
+		panic("Remove() without a current value")
+	}
+	if tc.nextNode == tc.last {
+		tc.nextNode = tc.last.succ()
+		tc.end = tc.nextNode == none
+	}
+	tc.del(tc.last)
+	tc.last = none
+}
+
+// Set replaces the value most recently returned with v, which must compare
+// equal to it.
+func (tc *threadCursor[N]) Set(v c.Comparable) {
+	var none N
+	if tc.last == none {
+		panic("Set() without a current value")
+	}
+	if v.CompareTo(*tc.last.dataRef()) != 0 {
+		panic("Set() value does not compare equal")
+	}
+	*tc.last.dataRef() = v
+}
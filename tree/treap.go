@@ -0,0 +1,108 @@
+package tree
+
+import (
+	"math/rand/v2"
+
+	c "github.com/dtromb/collections"
+)
+
+// Treap is a randomized binary search tree, with threads.  Each node is given
+// a random priority, and the tree is kept in heap order by priority, which
+// makes its shape that of a tree built by inserting the values in random
+// order: operations take expected O(ln(n)) time.  The shape depends only on
+// the values inserted and the random source the tree was created with.
+type Treap struct {
+	bsTree
+	src rand.Source
+}
+
+// NewTreap creates an empty Treap which draws priorities from src, or from
+// the global generator of math/rand/v2 if src is nil.
+func NewTreap(src rand.Source) *Treap {
+	return &Treap{src: src}
+}
+
+// priority returns a random priority for a new node.
+func (t *Treap) priority() uint64 {
+	if t.src == nil {
+		return rand.Uint64()
+	}
+	return t.src.Uint64()
+}
+
+func (t *Treap) Has(data c.Comparable) bool {
+	n, r := t.locate(data)
+	return n != nil && r == 0
+}
+
+// Lookup finds a value in the tree according to the given parameters.
+func (t *Treap) Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool) {
+	n, r := t.locate(data)
+	exact := n != nil && r == 0
+	if n = neighbor(lt, n, r); n == nil || (lt == c.EQ && !exact) {
+		return nil, exact
+	}
+	return n.data, exact
+}
+
+// Insert adds a value to the tree, replacing and returning any equal value.
+func (t *Treap) Insert(data c.Comparable) c.Comparable {
+	n, r := t.locate(data)
+	if n != nil && r == 0 {
+		old := n.data
+		n.data = data
+		return old
+	}
+	nn := &bsNode{data: data, prio: t.priority()}
+	t.attach(nn, n, r)
+	for nn.p != nil && nn.p.prio < nn.prio {
+		t.rotateUp(nn)
+	}
+	return nil
+}
+
+// Delete removes an element from the tree.  If the argument is found, the
+// value from the tree is returned along with boolean true.  If not, nil and
+// false is returned.
+func (t *Treap) Delete(data c.Comparable) (c.Comparable, bool) {
+	n, r := t.locate(data)
+	if n == nil || r != 0 {
+		return nil, false
+	}
+	t.deleteNode(n)
+	return n.data, true
+}
+
+// deleteNode rotates n down below its higher-priority children until it is a
+// leaf, and removes it.
+func (t *Treap) deleteNode(n *bsNode) {
+	for n.l != nil || n.r != nil {
+		if n.r == nil || n.l != nil && n.l.prio > n.r.prio {
+			t.rotateUp(n.l)
+		} else {
+			t.rotateUp(n.r)
+		}
+	}
+	t.replace(n, nil)
+	t.unthread(n)
+}
+
+// First opens a cursor positioned before the first value in the tree.
+func (t *Treap) First() c.Cursor {
+	return t.cursor(t.head, false, t.deleteNode)
+}
+
+// Last opens a cursor positioned after the last value in the tree.
+func (t *Treap) Last() c.Cursor {
+	return t.cursor(nil, true, t.deleteNode)
+}
+
+// GetCursor opens a cursor whose first value will be the value that would have
+// been returned by an equivalent Lookup() call.  The cursor is bidirectional
+// and can range past the start of the search.
+func (t *Treap) GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool) {
+	n, r := t.locate(data)
+	exact := n != nil && r == 0
+	n = neighbor(lt, n, r)
+	return t.cursor(n, n == nil && ascending(lt), t.deleteNode), exact
+}
@@ -2,15 +2,39 @@ package tree
 
 import c "github.com/dtromb/collections"
 
-type Tree interface {
+// TreeOf is an ordered collection of values of type T.
+type TreeOf[T any] interface {
 	Size() uint
-	Has(data c.Comparable) bool
-	Lookup(lt c.LookupType, data c.Comparable) (c.Comparable, bool)
-	Insert(data c.Comparable) c.Comparable
-	Delete(data c.Comparable) (c.Comparable, bool)
-	GetCursor(lt c.LookupType, data c.Comparable) (c.Cursor, bool)
-	First() c.Cursor
-	Last() c.Cursor
+	Has(data T) bool
+	Lookup(lt c.LookupType, data T) (T, bool)
+	Insert(data T) T
+	Delete(data T) (T, bool)
+	GetCursor(lt c.LookupType, data T) (c.CursorOf[T], bool)
+	First() c.CursorOf[T]
+	Last() c.CursorOf[T]
+}
+
+// Tree is an ordered collection of Comparable values.
+type Tree = TreeOf[c.Comparable]
+
+// RankedTreeOf is implemented by trees that maintain subtree sizes and can
+// answer order-statistic queries in O(ln(n)) time.
+type RankedTreeOf[T any] interface {
+	TreeOf[T]
+	Select(i uint) T
+	Rank(data T) (uint, bool)
+	CountRange(lo, hi T) uint
+}
+
+// RankedTree is a RankedTreeOf Comparable values.
+type RankedTree = RankedTreeOf[c.Comparable]
+
+// ascending reports whether a lookup of the given type that does not find an
+// acceptable value at the argument itself moves on to the values after it.
+// Cursors opened by such a lookup that find nothing are positioned after the
+// last value; the others are positioned before the first.
+func ascending(lt c.LookupType) bool {
+	return lt == c.GTE || lt == c.GT || lt == c.EQ
 }
 
 type TreeImplementation int
@@ -18,8 +42,34 @@ type TreeImplementation int
 const (
 	UNKNOWN TreeImplementation = iota
 	AVL_THREAD
+	RB_THREAD
+	BTREE
+	CONCURRENT_SKIPLIST
+	SKIPLIST
+	SPLAY
+	TREAP
 )
 
+func (ti TreeImplementation) String() string {
+	switch ti {
+	case AVL_THREAD:
+		return "AVL_THREAD"
+	case RB_THREAD:
+		return "RB_THREAD"
+	case BTREE:
+		return "BTREE"
+	case CONCURRENT_SKIPLIST:
+		return "CONCURRENT_SKIPLIST"
+	case SKIPLIST:
+		return "SKIPLIST"
+	case SPLAY:
+		return "SPLAY"
+	case TREAP:
+		return "TREAP"
+	}
+	return "UNKNOWN"
+}
+
 func NewTree(impl ...TreeImplementation) Tree {
 	if len(impl) == 0 {
 		return &AvlTree{}
@@ -30,6 +80,18 @@ func NewTree(impl ...TreeImplementation) Tree {
 	switch impl[0] {
 	case AVL_THREAD:
 		return &AvlTree{}
+	case RB_THREAD:
+		return &RbTree{}
+	case BTREE:
+		return NewBTree(DefaultBTreeOrder)
+	case CONCURRENT_SKIPLIST:
+		return NewConcurrentSkipList()
+	case SKIPLIST:
+		return NewSkipList(nil)
+	case SPLAY:
+		return NewSplayTree()
+	case TREAP:
+		return NewTreap(nil)
 	}
 	panic("Unknown tree implementation requested")
 }
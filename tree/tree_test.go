@@ -0,0 +1,398 @@
+package tree
+
+import (
+	"math/rand"
+	randv2 "math/rand/v2"
+	"testing"
+
+	c "github.com/dtromb/collections"
+)
+
+// implementations lists every tree implementation run through the conformance
+// suite, along with a checker that panics if the structure-specific invariants
+// of the implementation do not hold.
+var implementations = []struct {
+	name  string
+	new   func() Tree
+	check func(t Tree)
+}{
+	{AVL_THREAD.String(), func() Tree { return NewTree(AVL_THREAD) }, func(t Tree) { checkTree(t.(*AvlTree)) }},
+	{RB_THREAD.String(), func() Tree { return NewTree(RB_THREAD) }, func(t Tree) { checkRbTree(t.(*RbTree)) }},
+	{BTREE.String(), func() Tree { return NewTree(BTREE) }, func(t Tree) { checkBTree(t.(*BTree)) }},
+	{"BTREE/3", func() Tree { return NewBTree(3) }, func(t Tree) { checkBTree(t.(*BTree)) }},
+	{"BTREE/4", func() Tree { return NewBTree(4) }, func(t Tree) { checkBTree(t.(*BTree)) }},
+	{"PERSISTENT", func() Tree { return &persistentAdapter{t: &PersistentAvlTree{}} }, func(t Tree) { checkPavlNode(t.(*persistentAdapter).t.root) }},
+	{SKIPLIST.String(), func() Tree { return NewSkipList(randv2.NewPCG(1, 2)) }, func(t Tree) { checkSkipList(t.(*SkipList)) }},
+	{SPLAY.String(), func() Tree { return NewTree(SPLAY) }, func(t Tree) { checkBsTree(&t.(*SplayTree).bsTree) }},
+	{TREAP.String(), func() Tree { return NewTreap(randv2.NewPCG(1, 2)) }, func(t Tree) { checkTreap(t.(*Treap)) }},
+	{CONCURRENT_SKIPLIST.String(), func() Tree { return NewTree(CONCURRENT_SKIPLIST) }, func(t Tree) { checkConcurrentSkipList(t.(*ConcurrentSkipList)) }},
+}
+
+func forEachImplementation(t *testing.T, f func(t *testing.T, tr Tree, check func())) {
+	for _, ti := range implementations {
+		ti := ti
+		t.Run(ti.name, func(t *testing.T) {
+			tr := ti.new()
+			f(t, tr, func() { ti.check(tr) })
+		})
+	}
+}
+
+// checkContents verifies that iteration in both directions yields exactly
+// the values present in the model, in order.
+func checkContents(t *testing.T, tr Tree, model map[ComparableInt]bool, max int) {
+	var expected []ComparableInt
+	for i := 0; i < max; i++ {
+		if model[ComparableInt(i)] {
+			expected = append(expected, ComparableInt(i))
+		}
+	}
+	if int(tr.Size()) != len(expected) {
+		t.Fatalf("size %d, expected %d", tr.Size(), len(expected))
+	}
+	cur := tr.First()
+	for i, x := range expected {
+		if !cur.HasNext() {
+			t.Fatalf("forward cursor ended after %d of %d values", i, len(expected))
+		}
+		if v := cur.Next(); v != x {
+			t.Fatalf("forward cursor returned %v, expected %d", v, x)
+		}
+	}
+	if cur.HasNext() {
+		t.Fatal("forward cursor has values past the end")
+	}
+	cur = tr.Last()
+	for i := len(expected) - 1; i >= 0; i-- {
+		if !cur.HasPrev() {
+			t.Fatalf("backward cursor ended with %d values remaining", i+1)
+		}
+		if v := cur.Prev(); v != expected[i] {
+			t.Fatalf("backward cursor returned %v, expected %d", v, expected[i])
+		}
+	}
+	if cur.HasPrev() {
+		t.Fatal("backward cursor has values before the start")
+	}
+}
+
+func TestConformanceInsertDelete(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, tr Tree, check func()) {
+		var N = 3000
+		model := make(map[ComparableInt]bool)
+		rnd := rand.New(rand.NewSource(1))
+		for _, i := range rnd.Perm(N) {
+			if old := tr.Insert(ComparableInt(i)); old != nil {
+				t.Fatalf("unexpected old value %v", old)
+			}
+			model[ComparableInt(i)] = true
+			check()
+		}
+		if old := tr.Insert(ComparableInt(17)); old != ComparableInt(17) {
+			t.Fatalf("replacing insert returned %v", old)
+		}
+		checkContents(t, tr, model, N)
+		for i := 0; i < N*3/5; i++ {
+			x := ComparableInt(rnd.Intn(N))
+			old, found := tr.Delete(x)
+			if found != model[x] {
+				t.Fatalf("Delete(%d) found=%v, expected %v", x, found, model[x])
+			}
+			if found && old != x {
+				t.Fatalf("Delete(%d) returned %v", x, old)
+			}
+			delete(model, x)
+			check()
+		}
+		checkContents(t, tr, model, N)
+		for i := 0; i < N; i++ {
+			tr.Delete(ComparableInt(i))
+		}
+		check()
+		checkContents(t, tr, nil, 0)
+		if tr.First().HasNext() || tr.Last().HasPrev() {
+			t.Fatal("cursor on emptied tree has values")
+		}
+		tr.Insert(ComparableInt(5))
+		check()
+		checkContents(t, tr, map[ComparableInt]bool{5: true}, 6)
+	})
+}
+
+func TestConformanceLookup(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, tr Tree, check func()) {
+		if _, found := tr.Lookup(c.GTE, ComparableInt(0)); found {
+			t.Fatal("lookup in empty tree found a value")
+		}
+		if cur, _ := tr.GetCursor(c.GTE, ComparableInt(0)); cur.HasNext() || cur.HasPrev() {
+			t.Fatal("cursor on empty tree has values")
+		}
+		var N = 500
+		for i := 0; i < N; i++ {
+			tr.Insert(ComparableInt(2 * i))
+		}
+		check()
+		for x := -1; x <= 2*N; x++ {
+			exact := x >= 0 && x%2 == 0 && x < 2*N
+			if tr.Has(ComparableInt(x)) != exact {
+				t.Fatalf("Has(%d) != %v", x, exact)
+			}
+			ge, le := x, x
+			if x%2 != 0 {
+				ge, le = x+1, x-1
+			}
+			if le >= 2*N {
+				le = 2*N - 2
+			}
+			v, found := tr.Lookup(c.GTE, ComparableInt(x))
+			if found != exact {
+				t.Fatalf("Lookup(GTE, %d) exact=%v", x, found)
+			}
+			if ge < 2*N {
+				if v != ComparableInt(ge) {
+					t.Fatalf("Lookup(GTE, %d) = %v, expected %d", x, v, ge)
+				}
+			} else if v != nil {
+				t.Fatalf("Lookup(GTE, %d) = %v, expected nil", x, v)
+			}
+			v, _ = tr.Lookup(c.LTE, ComparableInt(x))
+			if le >= 0 {
+				if v != ComparableInt(le) {
+					t.Fatalf("Lookup(LTE, %d) = %v, expected %d", x, v, le)
+				}
+			} else if v != nil {
+				t.Fatalf("Lookup(LTE, %d) = %v, expected nil", x, v)
+			}
+
+			cur, _ := tr.GetCursor(c.GTE, ComparableInt(x))
+			if cur.HasNext() != (ge < 2*N) {
+				t.Fatalf("GetCursor(GTE, %d).HasNext() wrong", x)
+			}
+			if ge < 2*N {
+				if v := cur.Next(); v != ComparableInt(ge) {
+					t.Fatalf("GetCursor(GTE, %d).Next() = %v", x, v)
+				}
+				if v := cur.Prev(); v != ComparableInt(ge) {
+					t.Fatalf("GetCursor(GTE, %d) Prev() after Next() = %v", x, v)
+				}
+			}
+			if ge > 0 {
+				if v := cur.Prev(); v != ComparableInt(ge-2) {
+					t.Fatalf("GetCursor(GTE, %d) Prev() = %v", x, v)
+				}
+			}
+			// With no value <= x, an LTE cursor is positioned before the first value.
+			cur, _ = tr.GetCursor(c.LTE, ComparableInt(x))
+			if le < 0 {
+				le = 0
+			}
+			if v := cur.Next(); v != ComparableInt(le) {
+				t.Fatalf("GetCursor(LTE, %d).Next() = %v", x, v)
+			}
+		}
+	})
+}
+
+func TestConformanceStrictLookup(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, tr Tree, check func()) {
+		var N = 300
+		for i := 0; i < N; i++ {
+			tr.Insert(ComparableInt(2 * i))
+		}
+		check()
+		// find returns the first even value in [0,2N) from x stepping by dir,
+		// or -1 if there is none.
+		find := func(x, dir int) int {
+			if dir > 0 && x < 0 {
+				x = 0
+			} else if dir < 0 && x >= 2*N {
+				x = 2*N - 1
+			}
+			for ; x >= 0 && x < 2*N; x += dir {
+				if x%2 == 0 {
+					return x
+				}
+			}
+			return -1
+		}
+		for x := -2; x <= 2*N+1; x++ {
+			exact := find(x, 1) == x
+			eq := -1
+			if exact {
+				eq = x
+			}
+			for _, lc := range []struct {
+				name     string
+				lt       c.LookupType
+				want     int // value found, or -1
+				cursorAt int // first value from the cursor, or -1
+			}{
+				{"LT", c.LT, find(x-1, -1), find(x-1, -1)},
+				{"GT", c.GT, find(x+1, 1), find(x+1, 1)},
+				{"EQ", c.EQ, eq, find(x, 1)},
+			} {
+				v, found := tr.Lookup(lc.lt, ComparableInt(x))
+				if found != exact {
+					t.Fatalf("Lookup(%s, %d) exact=%v", lc.name, x, found)
+				}
+				if (lc.want < 0 && v != nil) || (lc.want >= 0 && v != ComparableInt(lc.want)) {
+					t.Fatalf("Lookup(%s, %d) = %v, expected %d", lc.name, x, v, lc.want)
+				}
+				cur, found := tr.GetCursor(lc.lt, ComparableInt(x))
+				if found != exact {
+					t.Fatalf("GetCursor(%s, %d) exact=%v", lc.name, x, found)
+				}
+				at := lc.cursorAt
+				if at < 0 && lc.lt == c.LT {
+					// Positioned before the first value, as for LTE.
+					at = 0
+				}
+				if at < 0 {
+					if cur.HasNext() {
+						t.Fatalf("GetCursor(%s, %d) has a next value", lc.name, x)
+					}
+					if v := cur.Prev(); v != ComparableInt(2*N-2) {
+						t.Fatalf("GetCursor(%s, %d).Prev() = %v", lc.name, x, v)
+					}
+				} else if v := cur.Next(); v != ComparableInt(at) {
+					t.Fatalf("GetCursor(%s, %d).Next() = %v, expected %d", lc.name, x, v, at)
+				}
+			}
+		}
+	})
+}
+
+func TestConformanceCursorRemove(t *testing.T) {
+	forEachImplementation(t, func(t *testing.T, tr Tree, check func()) {
+		if _, ok := tr.First().(c.MutableCursor); !ok {
+			t.Skip("cursors are not mutable")
+		}
+		var N = 1000
+		model := make(map[ComparableInt]bool)
+		for i := 0; i < N; i++ {
+			tr.Insert(ComparableInt(i))
+			model[ComparableInt(i)] = true
+		}
+		// Remove every third value scanning forward, replacing the others.
+		cur := tr.First().(c.MutableCursor)
+		for cur.HasNext() {
+			x := cur.Next().(ComparableInt)
+			if x%3 == 0 {
+				cur.Remove()
+				delete(model, x)
+			} else {
+				cur.Set(x)
+			}
+		}
+		check()
+		checkContents(t, tr, model, N)
+		// Remove every other remaining value scanning backward.
+		cur = tr.Last().(c.MutableCursor)
+		for odd := false; cur.HasPrev(); odd = !odd {
+			x := cur.Prev().(ComparableInt)
+			if odd {
+				cur.Remove()
+				delete(model, x)
+			}
+		}
+		check()
+		checkContents(t, tr, model, N)
+		// After switching direction the removed value is the one returned
+		// last, and the cursor carries on from the gap it leaves.
+		gc, _ := tr.GetCursor(c.GTE, ComparableInt(500))
+		a := gc.(c.MutableCursor)
+		x := a.Next()
+		y := a.Next()
+		if a.Prev() != y {
+			t.Fatal("Prev() after Next() did not return the same value")
+		}
+		a.Remove()
+		delete(model, y.(ComparableInt))
+		if v := a.Prev(); v != x {
+			t.Fatalf("Prev() after Remove() returned %v, expected %v", v, x)
+		}
+		check()
+		checkContents(t, tr, model, N)
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("second Remove() did not panic")
+				}
+			}()
+			a.Remove()
+			a.Remove()
+		}()
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Set() of an unequal value did not panic")
+				}
+			}()
+			a.Next()
+			a.Set(ComparableInt(-1))
+		}()
+	})
+}
+
+func BenchmarkLookup(b *testing.B) {
+	var N = 1000000
+	keys := rand.New(rand.NewSource(1)).Perm(N)
+	for _, ti := range implementations {
+		tr := ti.new()
+		for _, k := range keys {
+			tr.Insert(ComparableInt(k))
+		}
+		b.Run(ti.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tr.Has(ComparableInt(keys[i%N]))
+			}
+		})
+	}
+}
+
+// BenchmarkZipf replays a Zipf-distributed trace of operations against each
+// implementation, so that a few keys are much hotter than the rest.  The
+// lookup workload only reads; the mixed workload also inserts and deletes
+// one operation in ten each.  Ranks are mapped to keys through a permutation
+// so that the hot keys are scattered over the key space.
+func BenchmarkZipf(b *testing.B) {
+	const N, T = 100000, 1 << 20
+	rnd := rand.New(rand.NewSource(1))
+	perm := rnd.Perm(N)
+	zipf := rand.NewZipf(rnd, 1.1, 1, N-1)
+	trace := make([]ComparableInt, T)
+	ops := make([]int, T)
+	for i := range trace {
+		trace[i] = ComparableInt(perm[zipf.Uint64()])
+		ops[i] = rnd.Intn(10)
+	}
+	workloads := []struct {
+		name string
+		op   func(tr Tree, i int)
+	}{
+		{"lookup", func(tr Tree, i int) { tr.Has(trace[i]) }},
+		{"mixed", func(tr Tree, i int) {
+			switch ops[i] {
+			case 0:
+				tr.Insert(trace[i])
+			case 1:
+				tr.Delete(trace[i])
+			default:
+				tr.Has(trace[i])
+			}
+		}},
+	}
+	for _, w := range workloads {
+		for _, ti := range implementations {
+			tr := ti.new()
+			for _, k := range perm {
+				tr.Insert(ComparableInt(k))
+			}
+			b.Run(w.name+"/"+ti.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					w.op(tr, i%T)
+				}
+			})
+		}
+	}
+}